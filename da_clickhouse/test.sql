@@ -10,7 +10,7 @@ ORDER BY (registered_at);
 
 
 INSERT INTO users 
-SELECT * 
+SELECT id, nickname, email, hash_password, registered_at 
 FROM postgresql('forum-db-1:5430', 'forumdb', 'users', 'postgres', 'some_password') as postgr
 WHERE
 (SELECT count(*) FROM users) = 0
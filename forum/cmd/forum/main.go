@@ -1,14 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 
-	"github.com/LLIEPJIOK/forum/internal/application/forum"
+	"github.com/LLIEPJIOK/forum/internal/application/cli"
 )
 
 func main() {
-	if err := forum.Start(); err != nil {
-		slog.Default().Error(fmt.Sprintf("cannot start program: %s", err))
+	err := cli.New(os.Getenv("CONFIG_FILE"), os.Stdin, os.Stdout).Run(os.Args[1:])
+	if errors.Is(err, cli.ErrUsage) {
+		fmt.Fprintf(os.Stderr, "%s\n\n%s\n", err, cli.Usage)
+		os.Exit(2)
+	} else if err != nil {
+		slog.Default().Error(fmt.Sprintf("cannot run command: %s", err))
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+func (c *CLI) chat(args []string) error {
+	if len(args) == 0 || args[0] != "add-member" {
+		return ErrUsage
+	}
+
+	flags, output := c.newFlagSet("chat add-member")
+
+	p, rest, err := c.parse(flags, output, args[1:], 2)
+	if err != nil {
+		return err
+	}
+
+	chatID, err := parseID("chat id", rest[0])
+	if err != nil {
+		return err
+	}
+
+	userID, err := parseID("user id", rest[1])
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	chat, err := db.GetChat(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("db.GetChat(%d): %w", chatID, err)
+	}
+
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db.GetUserByID(%d): %w", userID, err)
+	}
+
+	if err := db.AddUserToChat(ctx, user, chat); err != nil {
+		return fmt.Errorf("db.AddUserToChat(%d, %d): %w", userID, chatID, err)
+	}
+
+	return p.print(
+		map[string]uint{"chat_id": chat.ID, "user_id": user.ID},
+		[]string{"CHAT", "USER"},
+		[][]string{{fmt.Sprintf("%d (%s)", chat.ID, chat.Name), user.Nickname}},
+	)
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+// Usage describes the commands of the forum binary.
+const Usage = `usage: forum [command]
+
+commands:
+  serve                               start the server (default)
+  migrate up | down [steps] | status  apply, roll back or list migrations
+  user create                         create a verified user, password on stdin
+  user disable <user-id>              stop the user from logging in
+  user set-role <user-id> <role>      set the role of the user
+  chat add-member <chat-id> <user-id> add the user to the chat
+  export [-file path]                 write users, chats, posts and messages as JSON
+  import [-file path]                 load an export into an empty database
+  reindex                             rebuild html, mentions and hashtags
+
+Commands other than serve and export accept -output table|json.
+The configuration is read from the file in CONFIG_FILE, if it is set,
+and from the environment.`
+
+var ErrUsage = errors.New("invalid command or arguments")
+
+// CLI runs the admin commands against the database of the forum.
+type CLI struct {
+	configPath string
+	stdin      io.Reader
+	stdout     io.Writer
+}
+
+// New returns the CLI that loads the configuration from the file at configPath,
+// if it is not empty, and from the environment.
+func New(configPath string, stdin io.Reader, stdout io.Writer) *CLI {
+	return &CLI{
+		configPath: configPath,
+		stdin:      stdin,
+		stdout:     stdout,
+	}
+}
+
+// Run runs the command in args. Without a command it starts the server.
+func (c *CLI) Run(args []string) error {
+	if len(args) == 0 {
+		return c.serve()
+	}
+
+	switch args[0] {
+	case "serve":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+
+		return c.serve()
+	case "migrate":
+		return c.migrate(args[1:])
+	case "user":
+		return c.user(args[1:])
+	case "chat":
+		return c.chat(args[1:])
+	case "export":
+		return c.export(args[1:])
+	case "import":
+		return c.importDump(args[1:])
+	case "reindex":
+		return c.reindex(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprintln(c.stdout, Usage)
+
+		return nil
+	default:
+		return ErrUsage
+	}
+}
+
+// printer writes results as a table or as indented JSON.
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+// newFlagSet returns the flags of the command with the shared -output flag.
+func (c *CLI) newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	output := flags.String("output", "table", "output format: table or json")
+
+	return flags, output
+}
+
+// anyArgs lets parse accept any number of positional arguments.
+const anyArgs = -1
+
+// parse parses the flags and returns the printer and the positional arguments,
+// which must be exactly count long unless count is anyArgs.
+func (c *CLI) parse(flags *flag.FlagSet, output *string, args []string, count int) (
+	*printer,
+	[]string,
+	error,
+) {
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", err, ErrUsage)
+	}
+
+	if count != anyArgs && flags.NArg() != count {
+		return nil, nil, ErrUsage
+	}
+
+	switch *output {
+	case "table":
+		return &printer{out: c.stdout}, flags.Args(), nil
+	case "json":
+		return &printer{out: c.stdout, json: true}, flags.Args(), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown output %q: %w", *output, ErrUsage)
+	}
+}
+
+// print writes the value as JSON or the rows under the header as a table.
+func (p *printer) print(value any, header []string, rows [][]string) error {
+	if p.json {
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("cannot print json: %w", err)
+		}
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot print table: %w", err)
+	}
+
+	return nil
+}
+
+func (p *printer) printUser(user *database.User) error {
+	email := ""
+	if user.Email != nil {
+		email = *user.Email
+	}
+
+	return p.print(
+		user,
+		[]string{"ID", "NICKNAME", "EMAIL", "ROLE", "VERIFIED", "DISABLED"},
+		[][]string{{
+			fmt.Sprint(user.ID),
+			user.Nickname,
+			email,
+			user.Role,
+			formatTime(user.VerifiedAt),
+			formatTime(user.DisabledAt),
+		}},
+	)
+}
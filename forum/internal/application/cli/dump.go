@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+// export writes the dump as JSON to the file or to stdout.
+func (c *CLI) export(args []string) error {
+	flags, output := c.newFlagSet("export")
+	file := flags.String("file", "", "file to write, stdout by default")
+
+	if _, _, err := c.parse(flags, output, args, 0); err != nil {
+		return err
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	dump, err := db.Export()
+	if err != nil {
+		return fmt.Errorf("db.Export(): %w", err)
+	}
+
+	out := c.stdout
+	if *file != "" {
+		// The export holds password hashes and webhook secrets.
+		f, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("cannot create file %q: %w", *file, err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(dump); err != nil {
+		return fmt.Errorf("cannot write dump: %w", err)
+	}
+
+	return nil
+}
+
+// importDump reads the dump from the file or from stdin and imports it.
+func (c *CLI) importDump(args []string) error {
+	flags, output := c.newFlagSet("import")
+	file := flags.String("file", "", "file to read, stdin by default")
+
+	p, _, err := c.parse(flags, output, args, 0)
+	if err != nil {
+		return err
+	}
+
+	in := c.stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("cannot open file %q: %w", *file, err)
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	dump := &database.Dump{}
+	if err := json.NewDecoder(in).Decode(dump); err != nil {
+		return fmt.Errorf("cannot read dump: %w", err)
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	if err := db.Import(dump); err != nil {
+		return fmt.Errorf("db.Import(): %w", err)
+	}
+
+	counts := map[string]int{
+		"users":    len(dump.Users),
+		"bots":     len(dump.Bots),
+		"chats":    len(dump.Chats),
+		"members":  len(dump.Members),
+		"posts":    len(dump.Posts),
+		"messages": len(dump.Messages),
+	}
+
+	return p.print(
+		counts,
+		[]string{"USERS", "BOTS", "CHATS", "MEMBERS", "POSTS", "MESSAGES"},
+		[][]string{{
+			fmt.Sprint(counts["users"]),
+			fmt.Sprint(counts["bots"]),
+			fmt.Sprint(counts["chats"]),
+			fmt.Sprint(counts["members"]),
+			fmt.Sprint(counts["posts"]),
+			fmt.Sprint(counts["messages"]),
+		}},
+	)
+}
+
+func (c *CLI) reindex(args []string) error {
+	flags, output := c.newFlagSet("reindex")
+
+	p, _, err := c.parse(flags, output, args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	reindexed, err := db.Reindex()
+	if err != nil {
+		return fmt.Errorf("db.Reindex(): %w", err)
+	}
+
+	return p.print(
+		reindexed,
+		[]string{"POSTS", "MESSAGES"},
+		[][]string{{fmt.Sprint(reindexed.Posts), fmt.Sprint(reindexed.Messages)}},
+	)
+}
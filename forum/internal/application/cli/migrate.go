@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+)
+
+// migrate applies or rolls back migrations and prints their status.
+func (c *CLI) migrate(args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	flags, output := c.newFlagSet("migrate " + args[0])
+
+	p, rest, err := c.parse(flags, output, args[1:], anyArgs)
+	if err != nil {
+		return err
+	}
+
+	steps := uint(1)
+	switch {
+	case args[0] == "up" && len(rest) == 0:
+	case args[0] == "down" && len(rest) == 1:
+		steps, err = parseID("steps", rest[0])
+		if err != nil {
+			return err
+		}
+
+		if steps == 0 {
+			return ErrUsage
+		}
+	case args[0] == "down" && len(rest) == 0:
+	case args[0] == "status" && len(rest) == 0:
+	default:
+		return ErrUsage
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		if err := db.Migrate(); err != nil {
+			return fmt.Errorf("db.Migrate(): %w", err)
+		}
+	case "down":
+		if err := db.MigrateDown(int(steps)); err != nil {
+			return fmt.Errorf("db.MigrateDown(%d): %w", steps, err)
+		}
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return fmt.Errorf("db.MigrationStatus(): %w", err)
+	}
+
+	rows := make([][]string, 0, len(statuses))
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = formatTime(status.AppliedAt)
+		}
+
+		rows = append(rows, []string{fmt.Sprintf("%04d", status.Version), status.Name, appliedAt})
+	}
+
+	return p.print(statuses, []string{"VERSION", "NAME", "APPLIED AT"}, rows)
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+func (c *CLI) user(args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.createUser(args[1:])
+	case "disable":
+		return c.disableUser(args[1:])
+	case "set-role":
+		return c.setUserRole(args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+// createUser creates a user that is verified from the start,
+// so ops can add accounts without a working mailer.
+// The password is read from the first line of stdin to keep it out of argv.
+func (c *CLI) createUser(args []string) error {
+	flags, output := c.newFlagSet("user create")
+	nickname := flags.String("nickname", "", "nickname of the user")
+	email := flags.String("email", "", "email of the user")
+	role := flags.String("role", database.RoleUser, "role of the user")
+
+	p, _, err := c.parse(flags, output, args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *nickname == "" || *email == "" {
+		return fmt.Errorf("nickname and email are required: %w", ErrUsage)
+	}
+
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("auth.HashPassword(): %w", err)
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	user := &database.User{
+		Nickname:     *nickname,
+		Email:        email,
+		HashPassword: hash,
+	}
+
+	ctx := context.Background()
+
+	err = db.Transaction(func(tx *database.Database) error {
+		if err := tx.AddUser(ctx, user); err != nil {
+			return fmt.Errorf("tx.AddUser(): %w", err)
+		}
+
+		if err := tx.MarkUserVerified(ctx, user.ID); err != nil {
+			return fmt.Errorf("tx.MarkUserVerified(%d): %w", user.ID, err)
+		}
+
+		updatedUser, err := tx.SetUserRole(ctx, user.ID, *role)
+		if err != nil {
+			return fmt.Errorf("tx.SetUserRole(%d): %w", user.ID, err)
+		}
+
+		user = updatedUser
+
+		return nil
+	})
+	if errors.Is(err, database.ErrUniqueConstraint) {
+		return fmt.Errorf("user with email %q already exists", *email)
+	} else if err != nil {
+		return err
+	}
+
+	return p.printUser(user)
+}
+
+func (c *CLI) disableUser(args []string) error {
+	flags, output := c.newFlagSet("user disable")
+
+	p, rest, err := c.parse(flags, output, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID("user id", rest[0])
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	user, err := db.DisableUser(context.Background(), id)
+	if err != nil {
+		return fmt.Errorf("db.DisableUser(%d): %w", id, err)
+	}
+
+	return p.printUser(user)
+}
+
+func (c *CLI) setUserRole(args []string) error {
+	flags, output := c.newFlagSet("user set-role")
+
+	p, rest, err := c.parse(flags, output, args, 2)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID("user id", rest[0])
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDatabase()
+	if err != nil {
+		return err
+	}
+
+	user, err := db.SetUserRole(context.Background(), id, rest[1])
+	if err != nil {
+		return fmt.Errorf("db.SetUserRole(%d): %w", id, err)
+	}
+
+	return p.printUser(user)
+}
+
+// readPassword reads the password from the first line of stdin.
+func (c *CLI) readPassword() (string, error) {
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("cannot read password from stdin: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password is required on stdin: %w", ErrUsage)
+	}
+
+	return password, nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/application/forum"
+	"github.com/LLIEPJIOK/forum/internal/config"
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+func (c *CLI) serve() error {
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		return fmt.Errorf("config.Load(): %w", err)
+	}
+
+	return forum.Start(cfg)
+}
+
+func (c *CLI) openDatabase() (*database.Database, error) {
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("config.Load(): %w", err)
+	}
+
+	db, err := forum.OpenDatabase(&cfg.Postgres)
+	if err != nil {
+		return nil, fmt.Errorf("forum.OpenDatabase(): %w", err)
+	}
+
+	return db, nil
+}
+
+func parseID(name, value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, ErrUsage)
+	}
+
+	return uint(id), nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.DateTime)
+}
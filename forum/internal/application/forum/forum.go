@@ -1,54 +1,350 @@
 package forum
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/LLIEPJIOK/forum/internal/bot"
+	"github.com/LLIEPJIOK/forum/internal/config"
 	"github.com/LLIEPJIOK/forum/internal/controller"
 	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/health"
+	"github.com/LLIEPJIOK/forum/internal/logging"
+	"github.com/LLIEPJIOK/forum/internal/mailer"
+	"github.com/LLIEPJIOK/forum/internal/metrics"
+	"github.com/LLIEPJIOK/forum/internal/notification"
+	"github.com/LLIEPJIOK/forum/internal/outbox"
+	"github.com/LLIEPJIOK/forum/internal/ratelimit"
 	"github.com/LLIEPJIOK/forum/internal/router"
+	"github.com/LLIEPJIOK/forum/internal/tracing"
+	"github.com/LLIEPJIOK/forum/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func Start() error {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_DB"),
-		os.Getenv("PGPORT"),
-	)
-	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Start runs the server until SIGINT or SIGTERM, then drains in-flight requests,
+// stops the background workers and closes the database and the log file.
+func Start(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exporter, err := newSpanExporter(ctx, &cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("cannot create span exporter: %w", err)
+	}
+
+	provider := tracing.NewProvider(exporter, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+
+	gormDB, err := openDB(&cfg.Postgres)
 	if err != nil {
 		return fmt.Errorf("cannot open db connection: %w", err)
 	}
 
-	db := database.New(gormDB)
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return fmt.Errorf("gormDB.DB(): %w", err)
+	}
+	defer sqlDB.Close()
+
+	registry := prometheus.NewRegistry()
+	stats := metrics.New(registry)
+	stats.RegisterRuntime()
+	stats.RegisterDBStats(sqlDB)
+
+	if err := gormDB.Use(stats.GormPlugin()); err != nil {
+		return fmt.Errorf("cannot use metrics plugin: %w", err)
+	}
+
+	if err := gormDB.Use(tracing.GormPlugin()); err != nil {
+		return fmt.Errorf("cannot use tracing plugin: %w", err)
+	}
+
+	hub := notification.NewHub()
+
+	db := database.New(gormDB, hub)
 	if err := db.Migrate(); err != nil {
 		return fmt.Errorf("cannot up migrations: %w", err)
 	}
 
-	if err := os.MkdirAll(os.Getenv("LOGS_DIR"), os.ModeDir); err != nil {
-		return fmt.Errorf("cannot make logs directory: %w", err)
+	logger, logs, err := newLogger(&cfg.Logs)
+	if err != nil {
+		return fmt.Errorf("cannot create logger: %w", err)
+	}
+	defer logs.Close()
+
+	logger.Info("effective config", "config", cfg.Redacted(), "method", "forum.Start")
+
+	mail, err := newMailer(&cfg.Mail)
+	if err != nil {
+		return fmt.Errorf("cannot create mailer: %w", err)
+	}
+
+	sender := startWorker("email sender", notification.NewEmailSender(db, mail, logger).Run)
+
+	signer, err := auth.NewSigner(cfg.Auth.TokenSecret)
+	if err != nil {
+		return fmt.Errorf("cannot create token signer: %w", err)
+	}
+
+	verifier := auth.NewVerifier(db, mail, signer)
+	authenticator := auth.NewAuthenticator(db, mail, signer, logger)
+
+	limiter, err := newRateLimiter(&cfg.RateLimit, db)
+	if err != nil {
+		return fmt.Errorf("cannot create rate limiter: %w", err)
+	}
+
+	bots := bot.NewDispatcher(db, logger)
+	if err := bots.Register("remind", bot.NewRemindBot(logger)); err != nil {
+		return fmt.Errorf("cannot register remind bot: %w", err)
+	}
+
+	if err := bots.Register("poll", bot.NewPollBot()); err != nil {
+		return fmt.Errorf("cannot register poll bot: %w", err)
+	}
+
+	// Bots stop after the controller, so commands in flight still reach them.
+	dispatcher := startWorker("bot dispatcher", bots.Run)
+
+	webhooks := webhook.NewDeliverer(db, logger)
+	deliverer := startWorker("webhook deliverer", webhooks.Run)
+
+	sink, err := newEventSink(cfg.Events.Sink, db, logger)
+	if err != nil {
+		return fmt.Errorf("cannot create event sink: %w", err)
+	}
+
+	relay := startWorker("outbox relay", outbox.NewRelay(db, sink, logger).Run)
+
+	incoming := webhook.NewIncoming(db)
+	checker := health.NewChecker(db, cfg.Health.Token, time.Duration(cfg.Health.Timeout))
+	timeouts := newQueryTimeouts(&cfg.API)
+
+	ctrl := controller.New(
+		db,
+		hub,
+		verifier,
+		authenticator,
+		bots,
+		webhooks,
+		incoming,
+		limiter,
+		checker,
+		stats,
+		timeouts,
+		logger,
+	)
+
+	routes := router.New(ctrl)
+	for route := range timeouts.Routes {
+		if !routes.HasRoute(route) {
+			return fmt.Errorf("query timeout of %q matches no route", route)
+		}
+	}
+
+	for route := range cfg.RateLimit.Routes {
+		if !routes.HasRoute(route) {
+			return fmt.Errorf("rate limit of %q matches no route", route)
+		}
+	}
+
+	server := routes.Server(&cfg.API)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var runErr error
+	select {
+	case err := <-serverErr:
+		runErr = fmt.Errorf("cannot run server: %w", err)
+	case <-ctx.Done():
+		logger.Info("shutting down", "method", "forum.Start")
 	}
 
-	file, err := os.OpenFile(
-		fmt.Sprintf("%s/%s", os.Getenv("LOGS_DIR"), os.Getenv("LOGS_FILE")),
-		os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
-		0o644,
+	// A second signal kills the process without waiting for the shutdown.
+	stop()
+
+	// Reminders write messages, the relay queues webhook deliveries for them
+	// and the deliverer sends them, so producers stop before their consumers.
+	shutdownErr := shutdown(
+		time.Duration(cfg.API.ShutdownTimeout),
+		checker,
+		server,
+		hub,
+		ctrl,
+		provider,
+		dispatcher,
+		relay,
+		deliverer,
+		sender,
 	)
+	if shutdownErr != nil {
+		logger.Error(fmt.Sprintf("cannot shut down: %s", shutdownErr), "method", "forum.Start")
+	}
+
+	return errors.Join(runErr, shutdownErr)
+}
+
+// OpenDatabase connects to the database of the forum for the admin commands.
+// Notifications it creates are stored but not pushed to connected clients.
+func OpenDatabase(cfg *config.Postgres) (*database.Database, error) {
+	gormDB, err := openDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open db connection: %w", err)
+	}
+
+	return database.New(gormDB, nil), nil
+}
+
+func openDB(cfg *config.Postgres) (*gorm.DB, error) {
+	gormDB, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("gormDB.DB(): %w", err)
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
+	return gormDB, nil
+}
+
+// newLogger returns the logger and the output to close after the last log line.
+func newLogger(cfg *config.Logs) (*slog.Logger, io.Closer, error) {
+	level, err := logging.ParseLevel(cfg.Level)
 	if err != nil {
-		return fmt.Errorf("cannot open file %q: %w", os.Getenv("LOGS_FILE"), err)
+		return nil, nil, err
+	}
+
+	switch cfg.Output {
+	case "file":
+		output := &lumberjack.Logger{
+			Filename:   filepath.Join(cfg.Dir, cfg.File),
+			MaxSize:    max(int(cfg.MaxSize>>20), 1),
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     int(math.Ceil(time.Duration(cfg.MaxAge).Hours() / 24)),
+			Compress:   cfg.Compress,
+		}
+
+		return logging.New(output, level), output, nil
+	case "stdout":
+		return logging.New(os.Stdout, level), io.NopCloser(nil), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown log output %q", cfg.Output)
+	}
+}
+
+func newMailer(cfg *config.Mail) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			cfg.SMTP.Address,
+			cfg.SMTP.Username,
+			cfg.SMTP.Password,
+			cfg.From,
+		)
+	case "file":
+		return mailer.NewFileMailer(cfg.Dir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
+
+func newRateLimiter(cfg *config.RateLimit, db *database.Database) (*ratelimit.Limiter, error) {
+	rules := ratelimit.Rules{
+		Default: ratelimit.Limit{
+			Burst:    cfg.DefaultBurst,
+			Interval: time.Duration(cfg.DefaultInterval),
+		},
+		Routes: make(map[string]ratelimit.Limit, len(cfg.Routes)),
+		Auth: ratelimit.Limit{
+			Burst:    cfg.AuthBurst,
+			Interval: time.Duration(cfg.AuthInterval),
+		},
+	}
+	for route, limit := range cfg.Routes {
+		rules.Routes[route] = ratelimit.Limit{
+			Burst:    limit.Burst,
+			Interval: time.Duration(limit.Interval),
+		}
 	}
 
-	logger := slog.New(slog.NewJSONHandler(file, &slog.HandlerOptions{}))
-	ctrl := controller.New(db, logger)
+	switch cfg.Store {
+	case "postgres":
+		return ratelimit.New(ratelimit.NewPostgresStore(db), rules), nil
+	case "memory":
+		return ratelimit.New(ratelimit.NewMemoryStore(), rules), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
 
-	rout := router.New(ctrl)
-	rout.Run(os.Getenv("API_ADDRESS"))
+// newQueryTimeouts applies the query timeouts of the config over the ones of the router.
+func newQueryTimeouts(cfg *config.API) controller.QueryTimeouts {
+	routes := maps.Clone(router.QueryTimeouts)
+	for route, timeout := range cfg.QueryTimeouts {
+		routes[route] = time.Duration(timeout)
+	}
 
-	return nil
+	return controller.QueryTimeouts{
+		Default: time.Duration(cfg.QueryTimeout),
+		Routes:  routes,
+	}
+}
+
+// newSpanExporter returns nil for the none exporter.
+func newSpanExporter(ctx context.Context, cfg *config.Tracing) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "otlp":
+		return otlptracehttp.New(
+			ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"),
+		)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown span exporter %q", cfg.Exporter)
+	}
+}
+
+func newEventSink(
+	sink string,
+	db *database.Database,
+	logger *slog.Logger,
+) (outbox.Sink, error) {
+	switch sink {
+	case "webhook":
+		return outbox.NewWebhookSink(db), nil
+	case "log":
+		return outbox.NewLogSink(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown event sink %q", sink)
+	}
 }
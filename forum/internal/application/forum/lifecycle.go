@@ -0,0 +1,93 @@
+package forum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/controller"
+	"github.com/LLIEPJIOK/forum/internal/health"
+	"github.com/LLIEPJIOK/forum/internal/notification"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// worker is a background loop that runs until its context is canceled.
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startWorker(name string, run func(ctx context.Context)) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		name:   name,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+
+		run(ctx)
+	}()
+
+	return w
+}
+
+// stop cancels the worker and waits until it returns or ctx is done.
+func (w *worker) stop(ctx context.Context) error {
+	w.cancel()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cannot stop %s: %w", w.name, ctx.Err())
+	}
+}
+
+// shutdown marks the server as not ready, stops it and then the workers
+// in the given order and flushes the spans, all within the shutdown timeout.
+func shutdown(
+	timeout time.Duration,
+	checker *health.Checker,
+	server *http.Server,
+	hub *notification.Hub,
+	ctrl *controller.Controller,
+	provider *sdktrace.TracerProvider,
+	workers ...*worker,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+
+	checker.ShutDown()
+
+	// Notification streams never end on their own, so they are closed
+	// before in-flight requests are drained.
+	hub.Close()
+
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cannot drain requests: %w", err))
+	}
+
+	if err := ctrl.Wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cannot finish background tasks: %w", err))
+	}
+
+	for _, w := range workers {
+		if err := w.stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := provider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cannot flush spans: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, so they are told apart from session tokens.
+const APIKeyPrefix = "fk_"
+
+const apiKeyIDSize = 4
+
+const (
+	ScopeUsersRead          = "users:read"
+	ScopeUsersWrite         = "users:write"
+	ScopePostsRead          = "posts:read"
+	ScopePostsWrite         = "posts:write"
+	ScopeChatsRead          = "chats:read"
+	ScopeChatsWrite         = "chats:write"
+	ScopeNotificationsRead  = "notifications:read"
+	ScopeNotificationsWrite = "notifications:write"
+)
+
+var Scopes = []string{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeChatsRead,
+	ScopeChatsWrite,
+	ScopeNotificationsRead,
+	ScopeNotificationsWrite,
+}
+
+var ErrInvalidAPIKey = errors.New("api key must have a name, known scopes and a future expiry")
+
+// NewAPIKey is a created key. The key itself is shown to the client only once.
+type NewAPIKey struct {
+	Key string `json:"key"`
+	*database.APIKey
+}
+
+func (a *Authenticator) CreateAPIKey(
+	ctx context.Context,
+	user *database.User,
+	name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (*NewAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(scopes) == 0 || (expiresAt != nil && !expiresAt.After(time.Now())) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAPIKey, scope)
+		}
+	}
+
+	id := make([]byte, apiKeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("cannot generate api key id: %w", err)
+	}
+
+	secret, _, err := newSecretToken()
+	if err != nil {
+		return nil, fmt.Errorf("newSecretToken(): %w", err)
+	}
+
+	prefix := APIKeyPrefix + hex.EncodeToString(id)
+	key := prefix + "_" + secret
+
+	apiKey := &database.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashSecretToken(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt: expiresAt,
+	}
+	if err := a.db.AddAPIKey(ctx, apiKey); err != nil {
+		return nil, fmt.Errorf("a.db.AddAPIKey(): %w", err)
+	}
+
+	return &NewAPIKey{
+		Key:    key,
+		APIKey: apiKey,
+	}, nil
+}
+
+// AuthenticateAPIKey returns the active key and its user and records the key usage.
+func (a *Authenticator) AuthenticateAPIKey(
+	ctx context.Context,
+	key string,
+) (*database.User, *database.APIKey, error) {
+	apiKey, err := a.db.GetActiveAPIKey(ctx, hashSecretToken(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrUnauthenticated
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("a.db.GetActiveAPIKey(): %w", err)
+	}
+
+	user, err := a.db.GetUserByID(ctx, apiKey.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrUnauthenticated
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("a.db.GetUserByID(%d): %w", apiKey.UserID, err)
+	}
+
+	if user.RemovedAt.Valid || user.DisabledAt != nil {
+		return nil, nil, ErrUnauthenticated
+	}
+
+	if err := a.db.TouchAPIKey(ctx, apiKey.ID, time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("a.db.TouchAPIKey(%d): %w", apiKey.ID, err)
+	}
+
+	return user, apiKey, nil
+}
+
+func (a *Authenticator) GetAPIKeys(
+	ctx context.Context,
+	user *database.User,
+) ([]database.APIKey, error) {
+	keys, err := a.db.GetUserAPIKeys(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("a.db.GetUserAPIKeys(%d): %w", user.ID, err)
+	}
+
+	return keys, nil
+}
+
+func (a *Authenticator) RevokeAPIKey(ctx context.Context, user *database.User, id uint) error {
+	if err := a.db.RevokeAPIKey(ctx, user.ID, id); err != nil {
+		return fmt.Errorf("a.db.RevokeAPIKey(%d): %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/mailer"
+	"gorm.io/gorm"
+)
+
+const (
+	sessionTTL       = 7 * 24 * time.Hour
+	passwordResetTTL = time.Hour
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUnauthenticated    = errors.New("session is invalid or expired")
+)
+
+type AuthDB interface {
+	GetUserByEmail(ctx context.Context, email string) (*database.User, error)
+	GetUserByID(ctx context.Context, id uint) (*database.User, error)
+
+	AddSession(ctx context.Context, session *database.Session) error
+	GetActiveSession(ctx context.Context, tokenHash string) (*database.Session, error)
+	RevokeSession(ctx context.Context, tokenHash string) error
+
+	AddPasswordResetToken(ctx context.Context, token *database.PasswordResetToken) error
+	ResetPassword(ctx context.Context, tokenHash, hashPassword string) (*database.User, error)
+
+	GetTwoFactor(ctx context.Context, userID uint) (*database.TwoFactor, error)
+	SetTwoFactorSecret(ctx context.Context, userID uint, secret string) error
+	ConfirmTwoFactor(ctx context.Context, userID uint, step int64, codeHashes []string) error
+	UseTwoFactorStep(ctx context.Context, userID uint, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) error
+	GetTwoFactorPolicy(ctx context.Context, role string) (*database.TwoFactorPolicy, error)
+
+	GetLoginThrottle(ctx context.Context, key string) (*database.LoginThrottle, error)
+	AddLoginFailure(
+		ctx context.Context,
+		key string,
+		at time.Time,
+		window time.Duration,
+	) (*database.LoginThrottle, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginThrottle(ctx context.Context, key string) error
+
+	AddAPIKey(ctx context.Context, key *database.APIKey) error
+	GetActiveAPIKey(ctx context.Context, keyHash string) (*database.APIKey, error)
+	GetUserAPIKeys(ctx context.Context, userID uint) ([]database.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uint, at time.Time) error
+	RevokeAPIKey(ctx context.Context, userID, id uint) error
+}
+
+// Authenticator logs users in with sessions, checks their second factor
+// and lets them recover their passwords.
+type Authenticator struct {
+	db     AuthDB
+	mailer mailer.Mailer
+	signer *Signer
+	logger *slog.Logger
+}
+
+func NewAuthenticator(
+	db AuthDB,
+	mailer mailer.Mailer,
+	signer *Signer,
+	logger *slog.Logger,
+) *Authenticator {
+	return &Authenticator{
+		db:     db,
+		mailer: mailer,
+		signer: signer,
+		logger: logger,
+	}
+}
+
+// LoginResult holds either a new session token or, when the user has
+// two-factor authentication enabled, a challenge for LoginTwoFactor.
+type LoginResult struct {
+	Token             string    `json:"token,omitempty"`
+	Challenge         string    `json:"challenge,omitempty"`
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// Login checks the credentials and starts a new session.
+// The returned token is shown to the client only once.
+// Repeated failures for the account or the client IP slow down and then lock further attempts.
+func (a *Authenticator) Login(
+	ctx context.Context,
+	email, password, ip string,
+) (*LoginResult, error) {
+	if err := a.checkThrottle(ctx, accountKey(email), ipKey(ip)); err != nil {
+		return nil, fmt.Errorf("a.checkThrottle(): %w", err)
+	}
+
+	user, err := a.db.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("a.db.GetUserByEmail(%q): %w", email, err)
+	}
+
+	if err != nil || user.RemovedAt.Valid || user.DisabledAt != nil ||
+		!CheckPassword(user.HashPassword, password) {
+		if err := a.recordFailure(ctx, email, ip); err != nil {
+			return nil, fmt.Errorf("a.recordFailure(): %w", err)
+		}
+
+		return nil, ErrInvalidCredentials
+	}
+
+	twoFactor, err := a.db.GetTwoFactor(ctx, user.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("a.db.GetTwoFactor(%d): %w", user.ID, err)
+	}
+
+	// Failures of the account are kept until a session is issued,
+	// so logging in with the password again does not reset wrong codes.
+	if err == nil && twoFactor.ConfirmedAt != nil {
+		expiresAt := time.Now().Add(loginChallengeTTL)
+
+		return &LoginResult{
+			Challenge:         a.signer.Sign(loginChallengePurpose, user.ID, expiresAt),
+			TwoFactorRequired: true,
+			ExpiresAt:         expiresAt,
+		}, nil
+	}
+
+	if err := a.db.ResetLoginThrottle(ctx, accountKey(email)); err != nil {
+		return nil, fmt.Errorf("a.db.ResetLoginThrottle(): %w", err)
+	}
+
+	result, err := a.startSession(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("a.startSession(%d): %w", user.ID, err)
+	}
+
+	return result, nil
+}
+
+func (a *Authenticator) startSession(
+	ctx context.Context,
+	user *database.User,
+) (*LoginResult, error) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return nil, fmt.Errorf("newSecretToken(): %w", err)
+	}
+
+	session := &database.Session{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(sessionTTL),
+	}
+	if err := a.db.AddSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("a.db.AddSession(): %w", err)
+	}
+
+	return &LoginResult{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// Authenticate returns the user of the active session with the token.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*database.User, error) {
+	session, err := a.db.GetActiveSession(ctx, hashSecretToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnauthenticated
+	} else if err != nil {
+		return nil, fmt.Errorf("a.db.GetActiveSession(): %w", err)
+	}
+
+	user, err := a.db.GetUserByID(ctx, session.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnauthenticated
+	} else if err != nil {
+		return nil, fmt.Errorf("a.db.GetUserByID(%d): %w", session.UserID, err)
+	}
+
+	if user.DisabledAt != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	return user, nil
+}
+
+func (a *Authenticator) Logout(ctx context.Context, token string) error {
+	if err := a.db.RevokeSession(ctx, hashSecretToken(token)); err != nil {
+		return fmt.Errorf("a.db.RevokeSession(): %w", err)
+	}
+
+	return nil
+}
+
+// ForgotPassword emails a single-use reset token to the active user with the email.
+// Unknown emails are silently ignored, so the caller cannot tell them apart.
+func (a *Authenticator) ForgotPassword(ctx context.Context, email string) error {
+	user, err := a.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("a.db.GetUserByEmail(%q): %w", email, err)
+	}
+
+	if user.RemovedAt.Valid {
+		return nil
+	}
+
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return fmt.Errorf("newSecretToken(): %w", err)
+	}
+
+	err = a.db.AddPasswordResetToken(ctx, &database.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("a.db.AddPasswordResetToken(): %w", err)
+	}
+
+	message, err := mailer.NewMessage(
+		email,
+		"Reset your password",
+		"password_reset",
+		map[string]any{
+			"Nickname": user.Nickname,
+			"Token":    token,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("mailer.NewMessage(): %w", err)
+	}
+
+	if err := a.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("a.mailer.Send(): %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with the reset token and revokes all sessions of the user.
+func (a *Authenticator) ResetPassword(ctx context.Context, token, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("HashPassword(): %w", err)
+	}
+
+	_, err = a.db.ResetPassword(ctx, hashSecretToken(token), hash)
+	if errors.Is(err, database.ErrTokenInvalid) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("a.db.ResetPassword(): %w", ErrInvalidToken)
+	} else if err != nil {
+		return fmt.Errorf("a.db.ResetPassword(): %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/mailer"
+	"gorm.io/gorm"
+)
+
+const (
+	failureWindow         = 15 * time.Minute
+	freeAttempts          = 3
+	maxAttemptDelay       = 30 * time.Second
+	accountLockThreshold  = 10
+	ipLockThreshold       = 50
+	lockDuration          = 15 * time.Minute
+	accountUnlockPurpose  = "account-unlock"
+	accountUnlockTokenTTL = 24 * time.Hour
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ThrottleError is returned when a login is rejected before checking credentials.
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkThrottle rejects the attempt if one of the keys is locked or its
+// progressive delay after the last failure has not passed yet.
+func (a *Authenticator) checkThrottle(ctx context.Context, keys ...string) error {
+	now := time.Now()
+
+	for _, key := range keys {
+		throttle, err := a.db.GetLoginThrottle(ctx, key)
+		if err != nil {
+			return fmt.Errorf("a.db.GetLoginThrottle(%q): %w", key, err)
+		}
+
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			return &ThrottleError{RetryAfter: throttle.LockedUntil.Sub(now)}
+		}
+
+		if throttle.Failures < freeAttempts || now.Sub(throttle.LastFailureAt) > failureWindow {
+			continue
+		}
+
+		delay := maxAttemptDelay
+		if shift := throttle.Failures - freeAttempts; shift < 5 {
+			delay = min(time.Second<<shift, maxAttemptDelay)
+		}
+
+		if next := throttle.LastFailureAt.Add(delay); now.Before(next) {
+			return &ThrottleError{RetryAfter: next.Sub(now)}
+		}
+	}
+
+	return nil
+}
+
+// recordFailure counts the failed attempt for the account and the client IP and
+// locks them once they reach their thresholds.
+func (a *Authenticator) recordFailure(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	thresholds := []struct {
+		key       string
+		threshold int
+	}{
+		{key: accountKey(email), threshold: accountLockThreshold},
+		{key: ipKey(ip), threshold: ipLockThreshold},
+	}
+	for _, threshold := range thresholds {
+		throttle, err := a.db.AddLoginFailure(ctx, threshold.key, now, failureWindow)
+		if err != nil {
+			return fmt.Errorf("a.db.AddLoginFailure(%q): %w", threshold.key, err)
+		}
+
+		if throttle.Failures < threshold.threshold {
+			continue
+		}
+
+		lockedUntil := now.Add(lockDuration)
+		if err := a.db.LockLogin(ctx, threshold.key, lockedUntil); err != nil {
+			return fmt.Errorf("a.db.LockLogin(%q): %w", threshold.key, err)
+		}
+
+		a.logger.Warn(
+			"login is locked after too many failed attempts",
+			"event", "security.login_locked",
+			"key", threshold.key,
+			"ip", ip,
+			"failures", throttle.Failures,
+			"locked_until", lockedUntil,
+			"method", "auth.recordFailure",
+		)
+
+		if threshold.key == accountKey(email) {
+			if err := a.sendUnlockEmail(ctx, email); err != nil {
+				a.logger.Info(
+					fmt.Sprintf("cannot send unlock email: %s", err),
+					"method", "auth.recordFailure",
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendUnlockEmail lets the owner of a locked account unlock it before the lock expires.
+func (a *Authenticator) sendUnlockEmail(ctx context.Context, email string) error {
+	user, err := a.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("a.db.GetUserByEmail(%q): %w", email, err)
+	}
+
+	if user.RemovedAt.Valid || user.Email == nil {
+		return nil
+	}
+
+	message, err := mailer.NewMessage(
+		*user.Email,
+		"Your account was locked",
+		"account_locked",
+		map[string]any{
+			"Nickname": user.Nickname,
+			"Token": a.signer.Sign(
+				accountUnlockPurpose,
+				user.ID,
+				time.Now().Add(accountUnlockTokenTTL),
+			),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("mailer.NewMessage(): %w", err)
+	}
+
+	if err := a.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("a.mailer.Send(): %w", err)
+	}
+
+	return nil
+}
+
+// Unlock resets failed logins of the account with the token from the lock email.
+func (a *Authenticator) Unlock(ctx context.Context, token string) error {
+	userID, err := a.signer.Parse(accountUnlockPurpose, token)
+	if err != nil {
+		return fmt.Errorf("a.signer.Parse(): %w", err)
+	}
+
+	if err := a.UnlockUser(ctx, userID); err != nil {
+		return fmt.Errorf("a.UnlockUser(%d): %w", userID, err)
+	}
+
+	return nil
+}
+
+// UnlockUser resets failed logins of the user's account.
+func (a *Authenticator) UnlockUser(ctx context.Context, userID uint) error {
+	user, err := a.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("a.db.GetUserByID(%d): %w", userID, err)
+	}
+
+	if user.Email == nil {
+		return nil
+	}
+
+	if err := a.db.ResetLoginThrottle(ctx, accountKey(*user.Email)); err != nil {
+		return fmt.Errorf("a.db.ResetLoginThrottle(): %w", err)
+	}
+
+	a.logger.Info(
+		"account is unlocked",
+		"event", "security.account_unlocked",
+		"user_id", user.ID,
+		"method", "auth.UnlockUser",
+	)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := NewSigner("secret")
+	if err != nil {
+		t.Fatalf("NewSigner() error: %v", err)
+	}
+
+	other, err := NewSigner("other secret")
+	if err != nil {
+		t.Fatalf("NewSigner() error: %v", err)
+	}
+
+	valid := signer.Sign("reset", 42, time.Now().Add(time.Hour))
+	_, mac, _ := strings.Cut(valid, ".")
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(strings.Replace(decodePayload(t, valid), "42.", "43.", 1)),
+	)
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		wantID  uint
+		wantErr error
+	}{
+		{name: "valid", purpose: "reset", token: valid, wantID: 42},
+		{
+			name:    "expired",
+			purpose: "reset",
+			token:   signer.Sign("reset", 42, time.Now().Add(-time.Second)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered payload",
+			purpose: "reset",
+			token:   payload + "." + mac,
+			wantErr: ErrInvalidToken,
+		},
+		{name: "tampered signature", purpose: "reset", token: valid + "A", wantErr: ErrInvalidToken},
+		{name: "purpose mismatch", purpose: "verify", token: valid, wantErr: ErrInvalidToken},
+		{
+			name:    "other secret",
+			purpose: "reset",
+			token:   other.Sign("reset", 42, time.Now().Add(time.Hour)),
+			wantErr: ErrInvalidToken,
+		},
+		{name: "no signature", purpose: "reset", token: "NDIuMA", wantErr: ErrInvalidToken},
+		{name: "empty", purpose: "reset", token: "", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := signer.Parse(tt.purpose, tt.token)
+			if id != tt.wantID || !errors.Is(err, tt.wantErr) {
+				t.Errorf("got (%d, %v), want (%d, %v)", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSignerEmptySecret(t *testing.T) {
+	if _, err := NewSigner(""); err == nil {
+		t.Errorf("got no error for an empty secret")
+	}
+}
+
+func decodePayload(t *testing.T, token string) string {
+	t.Helper()
+
+	encoded, _, _ := strings.Cut(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("cannot decode payload of %q: %v", token, err)
+	}
+
+	return string(payload)
+}
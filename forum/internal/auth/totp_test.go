@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors in base32.
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestMatchTOTPVectors(t *testing.T) {
+	// The RFC lists 8-digit codes, their last 6 digits are the 6-digit codes.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		step, ok := matchTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0), 0)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf(
+				"matchTOTP(%q) at %d = (%d, %v), want (%d, true)",
+				tt.code, tt.unix, step, ok, tt.unix/totpPeriod,
+			)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	// 287082 is the code of step 1 and 081804 is the code of step 37037036.
+	tests := []struct {
+		name         string
+		secret       string
+		code         string
+		unix         int64
+		lastUsedStep int64
+		wantStep     int64
+		wantOK       bool
+	}{
+		{name: "current step", secret: rfcSecret, code: "287082", unix: 59, wantStep: 1, wantOK: true},
+		{
+			name:     "lower case secret",
+			secret:   strings.ToLower(rfcSecret),
+			code:     "287082",
+			unix:     59,
+			wantStep: 1,
+			wantOK:   true,
+		},
+		{name: "previous step", secret: rfcSecret, code: "287082", unix: 89, wantStep: 1, wantOK: true},
+		{name: "next step", secret: rfcSecret, code: "287082", unix: 29, wantStep: 1, wantOK: true},
+		{name: "too old", secret: rfcSecret, code: "287082", unix: 90},
+		{name: "too early", secret: rfcSecret, code: "081804", unix: 1111111049},
+		{name: "reused step", secret: rfcSecret, code: "287082", unix: 59, lastUsedStep: 1},
+		{name: "older than used step", secret: rfcSecret, code: "287082", unix: 59, lastUsedStep: 2},
+		{name: "wrong code", secret: rfcSecret, code: "287083", unix: 59},
+		{name: "short code", secret: rfcSecret, code: "28708", unix: 59},
+		{name: "long code", secret: rfcSecret, code: "94287082", unix: 59},
+		{name: "invalid secret", secret: "not base32!", code: "287082", unix: 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(tt.secret, tt.code, time.Unix(tt.unix, 0), tt.lastUsedStep)
+			if step != tt.wantStep || ok != tt.wantOK {
+				t.Errorf("got (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
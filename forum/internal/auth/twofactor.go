@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"gorm.io/gorm"
+)
+
+const (
+	loginChallengePurpose = "login-challenge"
+	loginChallengeTTL     = 5 * time.Minute
+	recoveryCodesCount    = 10
+	recoveryCodeSize      = 5
+)
+
+var (
+	ErrInvalidCode          = errors.New("two-factor code is invalid")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").
+	WithPadding(base32.NoPadding)
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user.
+// It takes effect only after ConfirmTwoFactor.
+func (a *Authenticator) EnrollTwoFactor(
+	ctx context.Context,
+	user *database.User,
+) (*TwoFactorEnrollment, error) {
+	twoFactor, err := a.db.GetTwoFactor(ctx, user.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("a.db.GetTwoFactor(%d): %w", user.ID, err)
+	}
+
+	if err == nil && twoFactor.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("newTOTPSecret(): %w", err)
+	}
+
+	if err := a.db.SetTwoFactorSecret(ctx, user.ID, secret); err != nil {
+		return nil, fmt.Errorf("a.db.SetTwoFactorSecret(%d): %w", user.ID, err)
+	}
+
+	account := user.Nickname
+	if user.Email != nil {
+		account = *user.Email
+	}
+
+	return &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totpURI(account, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// the authenticator app works, and returns one-time recovery codes.
+func (a *Authenticator) ConfirmTwoFactor(
+	ctx context.Context,
+	user *database.User,
+	code string,
+) ([]string, error) {
+	twoFactor, err := a.db.GetTwoFactor(ctx, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotEnrolled
+	} else if err != nil {
+		return nil, fmt.Errorf("a.db.GetTwoFactor(%d): %w", user.ID, err)
+	}
+
+	if twoFactor.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	step, ok := matchTOTP(twoFactor.Secret, code, time.Now(), twoFactor.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for range recoveryCodesCount {
+		buf := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("cannot generate recovery code: %w", err)
+		}
+
+		code := recoveryCodeEncoding.EncodeToString(buf)
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, recoveryCodeHash(code))
+	}
+
+	if err := a.db.ConfirmTwoFactor(ctx, user.ID, step, hashes); err != nil {
+		return nil, fmt.Errorf("a.db.ConfirmTwoFactor(%d): %w", user.ID, err)
+	}
+
+	return codes, nil
+}
+
+// MissingTwoFactor reports whether the role of the user requires two-factor
+// authentication that the user has not enabled yet.
+func (a *Authenticator) MissingTwoFactor(ctx context.Context, user *database.User) (bool, error) {
+	policy, err := a.db.GetTwoFactorPolicy(ctx, user.Role)
+	if err != nil {
+		return false, fmt.Errorf("a.db.GetTwoFactorPolicy(%q): %w", user.Role, err)
+	}
+
+	if !policy.Required {
+		return false, nil
+	}
+
+	twoFactor, err := a.db.GetTwoFactor(ctx, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("a.db.GetTwoFactor(%d): %w", user.ID, err)
+	}
+
+	return twoFactor.ConfirmedAt == nil, nil
+}
+
+// LoginTwoFactor finishes the login started by Login with a TOTP or recovery code.
+// Wrong codes count as failed logins of the account.
+func (a *Authenticator) LoginTwoFactor(
+	ctx context.Context,
+	challenge, code, ip string,
+) (*LoginResult, error) {
+	userID, err := a.signer.Parse(loginChallengePurpose, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("a.signer.Parse(): %w", err)
+	}
+
+	user, err := a.db.GetUserByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, fmt.Errorf("a.db.GetUserByID(%d): %w", userID, err)
+	}
+
+	if user.DisabledAt != nil {
+		return nil, ErrInvalidToken
+	}
+
+	twoFactor, err := a.db.GetTwoFactor(ctx, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, fmt.Errorf("a.db.GetTwoFactor(%d): %w", user.ID, err)
+	}
+
+	email := ""
+	if user.Email != nil {
+		email = *user.Email
+	}
+
+	if err := a.checkThrottle(ctx, accountKey(email), ipKey(ip)); err != nil {
+		return nil, fmt.Errorf("a.checkThrottle(): %w", err)
+	}
+
+	if err := a.checkTwoFactorCode(ctx, twoFactor, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := a.recordFailure(ctx, email, ip); err != nil {
+				return nil, fmt.Errorf("a.recordFailure(): %w", err)
+			}
+		}
+
+		return nil, fmt.Errorf("a.checkTwoFactorCode(%d): %w", user.ID, err)
+	}
+
+	if err := a.db.ResetLoginThrottle(ctx, accountKey(email)); err != nil {
+		return nil, fmt.Errorf("a.db.ResetLoginThrottle(): %w", err)
+	}
+
+	result, err := a.startSession(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("a.startSession(%d): %w", user.ID, err)
+	}
+
+	return result, nil
+}
+
+// checkTwoFactorCode accepts a TOTP code that was not used before or an unused recovery code.
+func (a *Authenticator) checkTwoFactorCode(
+	ctx context.Context,
+	twoFactor *database.TwoFactor,
+	code string,
+) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := matchTOTP(twoFactor.Secret, code, time.Now(), twoFactor.LastUsedStep); ok {
+		err := a.db.UseTwoFactorStep(ctx, twoFactor.UserID, step)
+		if errors.Is(err, database.ErrTokenInvalid) {
+			return ErrInvalidCode
+		} else if err != nil {
+			return fmt.Errorf("a.db.UseTwoFactorStep(%d): %w", twoFactor.UserID, err)
+		}
+
+		return nil
+	}
+
+	err := a.db.UseRecoveryCode(ctx, twoFactor.UserID, recoveryCodeHash(code))
+	if errors.Is(err, database.ErrTokenInvalid) {
+		return ErrInvalidCode
+	} else if err != nil {
+		return fmt.Errorf("a.db.UseRecoveryCode(%d): %w", twoFactor.UserID, err)
+	}
+
+	return nil
+}
+
+// recoveryCodeHash returns the stored hash of the recovery code
+// as the user types it, in any case and with or without the dash.
+func recoveryCodeHash(code string) string {
+	return hashSecretToken(strings.ToLower(strings.ReplaceAll(code, "-", "")))
+}
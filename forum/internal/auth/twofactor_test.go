@@ -0,0 +1,31 @@
+package auth
+
+import "testing"
+
+func TestRecoveryCodeHash(t *testing.T) {
+	// ConfirmTwoFactor stores the hash of the raw code and shows it as "abcd-efgh".
+	stored := recoveryCodeHash("abcdefgh")
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "abcd-efgh", want: true},
+		{code: "abcdefgh", want: true},
+		{code: "ABCD-EFGH", want: true},
+		{code: "AbCd-eFgH", want: true},
+		{code: "abcd-efgi", want: false},
+		{code: "abcd efgh", want: false},
+		{code: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := recoveryCodeHash(tt.code) == stored; got != tt.want {
+			t.Errorf("recoveryCodeHash(%q) matches = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	if stored == "abcdefgh" || stored != hashSecretToken("abcdefgh") {
+		t.Errorf("recovery code is stored as %q, want its SHA-256 hash", stored)
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/mailer"
+	"gorm.io/gorm"
+)
+
+const (
+	verificationPurpose    = "email-verification"
+	verificationTTL        = 24 * time.Hour
+	verificationResendGap  = time.Minute
+	verificationDailyLimit = 5
+)
+
+var ErrTooManyRequests = errors.New("too many requests")
+
+type VerificationDB interface {
+	GetUserByEmail(ctx context.Context, email string) (*database.User, error)
+	AddVerificationToken(ctx context.Context, token *database.VerificationToken) error
+	CountVerificationTokens(ctx context.Context, userID uint, since time.Time) (int64, error)
+	VerifyUserEmail(ctx context.Context, tokenID uint) (*database.User, error)
+}
+
+// Verifier confirms that users own the email they registered with.
+type Verifier struct {
+	db     VerificationDB
+	mailer mailer.Mailer
+	signer *Signer
+}
+
+func NewVerifier(db VerificationDB, mailer mailer.Mailer, signer *Signer) *Verifier {
+	return &Verifier{
+		db:     db,
+		mailer: mailer,
+		signer: signer,
+	}
+}
+
+// Send emails a new single-use verification token to the user.
+func (v *Verifier) Send(ctx context.Context, user *database.User) error {
+	if user.Email == nil {
+		return fmt.Errorf("user with id = %d has no email", user.ID)
+	}
+
+	token := &database.VerificationToken{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(verificationTTL),
+	}
+	if err := v.db.AddVerificationToken(ctx, token); err != nil {
+		return fmt.Errorf("v.db.AddVerificationToken(): %w", err)
+	}
+
+	message, err := mailer.NewMessage(
+		*user.Email,
+		"Confirm your email",
+		"verification",
+		map[string]any{
+			"Nickname": user.Nickname,
+			"Token":    v.signer.Sign(verificationPurpose, token.ID, token.ExpiresAt),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("mailer.NewMessage(): %w", err)
+	}
+
+	if err := v.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("v.mailer.Send(): %w", err)
+	}
+
+	return nil
+}
+
+// Resend emails a new token to the unverified user with the given email.
+// Unknown and already verified emails are silently ignored.
+func (v *Verifier) Resend(ctx context.Context, email string) error {
+	user, err := v.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("v.db.GetUserByEmail(%q): %w", email, err)
+	}
+
+	if user.VerifiedAt != nil || user.RemovedAt.Valid {
+		return nil
+	}
+
+	now := time.Now()
+	limits := []struct {
+		since time.Time
+		limit int64
+	}{
+		{since: now.Add(-verificationResendGap), limit: 1},
+		{since: now.Add(-24 * time.Hour), limit: verificationDailyLimit},
+	}
+	for _, limit := range limits {
+		count, err := v.db.CountVerificationTokens(ctx, user.ID, limit.since)
+		if err != nil {
+			return fmt.Errorf("v.db.CountVerificationTokens(%d): %w", user.ID, err)
+		}
+
+		if count >= limit.limit {
+			return ErrTooManyRequests
+		}
+	}
+
+	if err := v.Send(ctx, user); err != nil {
+		return fmt.Errorf("v.Send(%d): %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// Verify uses the token and returns the verified user.
+func (v *Verifier) Verify(ctx context.Context, token string) (*database.User, error) {
+	tokenID, err := v.signer.Parse(verificationPurpose, token)
+	if err != nil {
+		return nil, fmt.Errorf("v.signer.Parse(): %w", err)
+	}
+
+	user, err := v.db.VerifyUserEmail(ctx, tokenID)
+	if errors.Is(err, database.ErrTokenInvalid) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("v.db.VerifyUserEmail(%d): %w", tokenID, ErrInvalidToken)
+	} else if err != nil {
+		return nil, fmt.Errorf("v.db.VerifyUserEmail(%d): %w", tokenID, err)
+	}
+
+	return user, nil
+}
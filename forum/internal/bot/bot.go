@@ -0,0 +1,296 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/webhook"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	maxResponseSize = 64 << 10
+)
+
+var ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+
+// Command is a chat message that starts with /name. Commands written as
+// /name@bot go only to the bot with this nickname.
+type Command struct {
+	BotID     uint   `json:"bot_id"`
+	ChatID    uint   `json:"chat_id"`
+	MessageID uint   `json:"message_id"`
+	SenderID  uint   `json:"sender_id"`
+	Sender    string `json:"sender"`
+	Name      string `json:"command"`
+	Args      string `json:"args"`
+	target    string
+}
+
+// Replier sends messages from the bot to the chat of the command.
+type Replier interface {
+	Reply(ctx context.Context, text string) error
+}
+
+// Handler is an in-process bot.
+type Handler interface {
+	Commands() []string
+	Handle(ctx context.Context, cmd *Command, replier Replier) error
+}
+
+// Runner is a handler with background work, such as pending reminders.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+type BotDB interface {
+	GetUserByID(ctx context.Context, id uint) (*database.User, error)
+	AddMessage(ctx context.Context, message *database.Message) error
+	AddBot(bot *database.Bot) error
+	EnsureBot(nickname string) (*database.Bot, error)
+	GetChatBots(chatID uint) ([]database.Bot, error)
+}
+
+// Dispatcher passes commands from chat messages to the bots that are members of the chat.
+type Dispatcher struct {
+	db       BotDB
+	handlers map[string]Handler
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+func NewDispatcher(db BotDB, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		db:       db,
+		handlers: make(map[string]Handler),
+		client:   &http.Client{Timeout: webhookTimeout},
+		logger:   logger,
+	}
+}
+
+// Register makes the handler the in-process bot with the nickname
+// and creates its account if it does not exist.
+func (d *Dispatcher) Register(nickname string, handler Handler) error {
+	if _, err := d.db.EnsureBot(nickname); err != nil {
+		return fmt.Errorf("d.db.EnsureBot(%q): %w", nickname, err)
+	}
+
+	d.handlers[nickname] = handler
+
+	return nil
+}
+
+// Run runs the registered handlers that are runners until ctx is done
+// and waits for them to stop.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, handler := range d.handlers {
+		runner, ok := handler.(Runner)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.Run(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// AddWebhookBot creates a bot that gets commands on the webhook URL.
+// The returned secret signs the requests and is shown only once.
+func (d *Dispatcher) AddWebhookBot(nickname, webhookURL string) (*database.Bot, string, error) {
+	if !webhook.ValidURL(webhookURL) {
+		return nil, "", ErrInvalidWebhookURL
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("webhook.NewSecret(): %w", err)
+	}
+
+	bot := &database.Bot{
+		User:       database.User{Nickname: nickname},
+		WebhookURL: webhookURL,
+		Secret:     secret,
+	}
+	if err := d.db.AddBot(bot); err != nil {
+		return nil, "", fmt.Errorf("d.db.AddBot(%q): %w", nickname, err)
+	}
+
+	return bot, bot.Secret, nil
+}
+
+// Dispatch passes the command in the message to the bots of its chat.
+// Messages of bots are ignored, so bots cannot command each other.
+func (d *Dispatcher) Dispatch(ctx context.Context, message *database.Message) error {
+	cmd, ok := parseCommand(message.Content)
+	if !ok {
+		return nil
+	}
+
+	sender, err := d.db.GetUserByID(ctx, message.SenderID)
+	if err != nil {
+		return fmt.Errorf("d.db.GetUserByID(%d): %w", message.SenderID, err)
+	}
+
+	if sender.IsBot {
+		return nil
+	}
+
+	bots, err := d.db.GetChatBots(message.ChatID)
+	if err != nil {
+		return fmt.Errorf("d.db.GetChatBots(%d): %w", message.ChatID, err)
+	}
+
+	cmd.ChatID = message.ChatID
+	cmd.MessageID = message.ID
+	cmd.SenderID = sender.ID
+	cmd.Sender = sender.Nickname
+
+	var errs []error
+	for _, bot := range bots {
+		if cmd.target != "" && !strings.EqualFold(cmd.target, bot.User.Nickname) {
+			continue
+		}
+
+		botCmd := *cmd
+		botCmd.BotID = bot.UserID
+
+		if err := d.handle(ctx, &bot, &botCmd); err != nil {
+			errs = append(errs, fmt.Errorf("d.handle(%q): %w", bot.User.Nickname, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) handle(ctx context.Context, bot *database.Bot, cmd *Command) error {
+	replier := &chatReplier{
+		db:     d.db,
+		botID:  bot.UserID,
+		chatID: cmd.ChatID,
+	}
+
+	if bot.WebhookURL != "" {
+		return d.callWebhook(ctx, bot, cmd, replier)
+	}
+
+	handler, ok := d.handlers[bot.User.Nickname]
+	if !ok || !slices.Contains(handler.Commands(), cmd.Name) {
+		return nil
+	}
+
+	return handler.Handle(ctx, cmd, replier)
+}
+
+// callWebhook posts the signed command to the bot and sends the text
+// of the response, if any, to the chat.
+func (d *Dispatcher) callWebhook(
+	ctx context.Context,
+	bot *database.Bot,
+	cmd *Command,
+	replier Replier,
+) error {
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("cannot marshal command: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		bot.WebhookURL,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot create webhook request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhook.SignatureHeader, webhook.Sign(bot.Secret, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("cannot call webhook: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNoContent {
+		return nil
+	} else if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+
+	var reply struct {
+		Text string `json:"text"`
+	}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&reply)
+	if err != nil {
+		return fmt.Errorf("cannot decode webhook response: %w", err)
+	}
+
+	if reply.Text == "" {
+		return nil
+	}
+
+	return replier.Reply(ctx, reply.Text)
+}
+
+// parseCommand parses messages like "/name@bot args".
+func parseCommand(content string) (*Command, bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "/") {
+		return nil, false
+	}
+
+	name, args := content[1:], ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, args = name[:i], name[i:]
+	}
+
+	name, target, _ := strings.Cut(name, "@")
+	if name == "" {
+		return nil, false
+	}
+
+	return &Command{
+		Name:   strings.ToLower(name),
+		Args:   strings.TrimSpace(args),
+		target: target,
+	}, true
+}
+
+type chatReplier struct {
+	db     BotDB
+	botID  uint
+	chatID uint
+}
+
+func (r *chatReplier) Reply(ctx context.Context, text string) error {
+	message := &database.Message{
+		Content:  text,
+		SenderID: r.botID,
+		ChatID:   r.chatID,
+	}
+	if err := r.db.AddMessage(ctx, message); err != nil {
+		return fmt.Errorf("r.db.AddMessage(): %w", err)
+	}
+
+	return nil
+}
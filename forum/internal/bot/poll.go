@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+)
+
+type poll struct {
+	chatID   uint
+	question string
+	options  []string
+	votes    map[uint]int
+}
+
+// PollBot handles "/poll <question> | <option> | <option>", "/vote <poll> <option>"
+// and "/results <poll>". Polls are kept in memory and are lost on restart.
+type PollBot struct {
+	mu    sync.Mutex
+	polls []*poll
+}
+
+func NewPollBot() *PollBot {
+	return &PollBot{}
+}
+
+func (b *PollBot) Commands() []string {
+	return []string{"poll", "vote", "results"}
+}
+
+func (b *PollBot) Handle(ctx context.Context, cmd *Command, replier Replier) error {
+	var reply string
+	switch cmd.Name {
+	case "poll":
+		reply = b.start(cmd)
+	case "vote":
+		reply = b.vote(cmd)
+	case "results":
+		reply = b.results(cmd)
+	}
+
+	return replier.Reply(ctx, reply)
+}
+
+func (b *PollBot) start(cmd *Command) string {
+	parts := strings.Split(cmd.Args, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	question, options := parts[0], parts[1:]
+	if question == "" || len(options) < minPollOptions || len(options) > maxPollOptions ||
+		slices.Contains(options, "") {
+		return fmt.Sprintf(
+			"Usage: `/poll <question> | <option> | <option>` with %d to %d options.",
+			minPollOptions,
+			maxPollOptions,
+		)
+	}
+
+	b.mu.Lock()
+	b.polls = append(b.polls, &poll{
+		chatID:   cmd.ChatID,
+		question: question,
+		options:  options,
+		votes:    make(map[uint]int),
+	})
+	id := len(b.polls)
+	b.mu.Unlock()
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "**Poll #%d: %s**\n\n", id, question)
+	for i, option := range options {
+		fmt.Fprintf(&text, "%d. %s\n", i+1, option)
+	}
+	fmt.Fprintf(&text, "\nVote with `/vote %d <option>`.", id)
+
+	return text.String()
+}
+
+func (b *PollBot) vote(cmd *Command) string {
+	fields := strings.Fields(cmd.Args)
+	if len(fields) != 2 {
+		return "Usage: `/vote <poll> <option>`."
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, id, ok := b.find(cmd.ChatID, fields[0])
+	if !ok {
+		return fmt.Sprintf("There is no poll #%s in this chat.", fields[0])
+	}
+
+	option, err := strconv.Atoi(fields[1])
+	if err != nil || option < 1 || option > len(p.options) {
+		return fmt.Sprintf("Poll #%d has options from 1 to %d.", id, len(p.options))
+	}
+
+	p.votes[cmd.SenderID] = option - 1
+
+	return fmt.Sprintf("@%s voted in poll #%d.", cmd.Sender, id)
+}
+
+func (b *PollBot) results(cmd *Command) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, id, ok := b.find(cmd.ChatID, cmd.Args)
+	if !ok {
+		return fmt.Sprintf("There is no poll #%s in this chat.", cmd.Args)
+	}
+
+	counts := make([]int, len(p.options))
+	for _, option := range p.votes {
+		counts[option]++
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "**Poll #%d: %s**\n\n", id, p.question)
+	for i, option := range p.options {
+		fmt.Fprintf(&text, "%d. %s: %d\n", i+1, option, counts[i])
+	}
+
+	return text.String()
+}
+
+// find returns the poll with the id if it was started in the chat.
+// It must be called with b.mu held.
+func (b *PollBot) find(chatID uint, rawID string) (*poll, int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(rawID), "#"))
+	if err != nil || id < 1 || id > len(b.polls) || b.polls[id-1].chatID != chatID {
+		return nil, 0, false
+	}
+
+	return b.polls[id-1], id, true
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxReminderDelay = 7 * 24 * time.Hour
+	maxUserReminders = 10
+	maxReminders     = 10_000
+	reminderTimeout  = 10 * time.Second
+)
+
+type reminderKey struct {
+	chatID   uint
+	senderID uint
+}
+
+type reminder struct {
+	key   reminderKey
+	timer *time.Timer
+}
+
+// RemindBot handles "/remind <duration> <text>" and mentions the sender with the text
+// after the duration. Reminders are kept in memory and are lost on restart.
+// A user can have up to maxUserReminders pending reminders in a chat.
+type RemindBot struct {
+	mu        sync.Mutex
+	reminders map[uint64]*reminder
+	pending   map[reminderKey]int
+	nextID    uint64
+	stopped   bool
+	sending   sync.WaitGroup
+	logger    *slog.Logger
+}
+
+func NewRemindBot(logger *slog.Logger) *RemindBot {
+	return &RemindBot{
+		reminders: make(map[uint64]*reminder),
+		pending:   make(map[reminderKey]int),
+		logger:    logger,
+	}
+}
+
+func (b *RemindBot) Commands() []string {
+	return []string{"remind"}
+}
+
+func (b *RemindBot) Handle(ctx context.Context, cmd *Command, replier Replier) error {
+	rawDelay, text, _ := strings.Cut(cmd.Args, " ")
+	text = strings.TrimSpace(text)
+
+	delay, err := time.ParseDuration(rawDelay)
+	if err != nil || delay <= 0 || delay > maxReminderDelay || text == "" {
+		return replier.Reply(
+			ctx,
+			"Usage: `/remind <duration> <text>` with a duration of at most 7 days, "+
+				"for example `/remind 1h30m standup`.",
+		)
+	}
+
+	return replier.Reply(ctx, b.schedule(cmd, delay, text, replier))
+}
+
+// Run keeps the reminders until ctx is done. Then it drops the pending reminders
+// and waits for the ones being sent.
+func (b *RemindBot) Run(ctx context.Context) {
+	<-ctx.Done()
+
+	b.mu.Lock()
+	b.stopped = true
+	for id, reminder := range b.reminders {
+		if reminder.timer.Stop() {
+			b.sending.Done()
+		}
+
+		delete(b.reminders, id)
+	}
+	clear(b.pending)
+	b.mu.Unlock()
+
+	b.sending.Wait()
+}
+
+// schedule starts the reminder and returns the reply to the command.
+func (b *RemindBot) schedule(
+	cmd *Command,
+	delay time.Duration,
+	text string,
+	replier Replier,
+) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := reminderKey{chatID: cmd.ChatID, senderID: cmd.SenderID}
+	switch {
+	case b.stopped:
+		return "Reminders are not available while the forum shuts down."
+	case b.pending[key] >= maxUserReminders:
+		return fmt.Sprintf("You already have %d pending reminders in this chat.", maxUserReminders)
+	case len(b.reminders) >= maxReminders:
+		return "Too many reminders are pending, try again later."
+	}
+
+	id := b.nextID
+	b.nextID++
+
+	// Every reminder is counted until it is sent or stopped by Run.
+	b.sending.Add(1)
+	b.pending[key]++
+	b.reminders[id] = &reminder{
+		key: key,
+		timer: time.AfterFunc(delay, func() {
+			defer b.sending.Done()
+
+			b.forget(id)
+			b.remind(replier, cmd.Sender, text)
+		}),
+	}
+
+	return fmt.Sprintf("Okay, I will remind you in %s.", delay)
+}
+
+func (b *RemindBot) forget(id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reminder, ok := b.reminders[id]
+	if !ok {
+		return
+	}
+
+	delete(b.reminders, id)
+	b.pending[reminder.key]--
+	if b.pending[reminder.key] == 0 {
+		delete(b.pending, reminder.key)
+	}
+}
+
+func (b *RemindBot) remind(replier Replier, sender, text string) {
+	// The command context is done by now, the reminder has nothing to inherit from it.
+	ctx, cancel := context.WithTimeout(context.Background(), reminderTimeout)
+	defer cancel()
+
+	err := replier.Reply(ctx, fmt.Sprintf("@%s reminder: %s", sender, text))
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("cannot send reminder: %s", err), "method", "bot.RemindBot.remind")
+	}
+}
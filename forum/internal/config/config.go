@@ -0,0 +1,474 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of secret fields in Redacted.
+const redacted = "REDACTED"
+
+var ErrUnknownFormat = errors.New("config file must be .yaml, .yml or .toml")
+
+// Config is the configuration of the server and the admin commands. It is loaded
+// from the defaults, then the optional file, then the environment variables named
+// in the env tags, and every later source overrides the earlier ones.
+// Fields tagged secret are hidden by Redacted.
+type Config struct {
+	API       API       `yaml:"api" toml:"api"`
+	Postgres  Postgres  `yaml:"postgres" toml:"postgres"`
+	Logs      Logs      `yaml:"logs" toml:"logs"`
+	Auth      Auth      `yaml:"auth" toml:"auth"`
+	Mail      Mail      `yaml:"mail" toml:"mail"`
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	Events    Events    `yaml:"events" toml:"events"`
+	Health    Health    `yaml:"health" toml:"health"`
+	Tracing   Tracing   `yaml:"tracing" toml:"tracing"`
+}
+
+type API struct {
+	Address           string   `yaml:"address" toml:"address" env:"API_ADDRESS"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" env:"API_READ_TIMEOUT"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"API_READ_HEADER_TIMEOUT"`
+	// WriteTimeout does not apply to notification streams.
+	WriteTimeout  Duration `yaml:"write_timeout" toml:"write_timeout" env:"API_WRITE_TIMEOUT"`
+	IdleTimeout   Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"API_IDLE_TIMEOUT"`
+	MaxHeaderSize Size     `yaml:"max_header_size" toml:"max_header_size" env:"API_MAX_HEADER_SIZE"`
+	// QueryTimeout is how long a request may wait for the database. QueryTimeouts
+	// override it for routes like "GET /post/list/", zero disables it.
+	QueryTimeout  Duration            `yaml:"query_timeout" toml:"query_timeout" env:"API_QUERY_TIMEOUT"`
+	QueryTimeouts map[string]Duration `yaml:"query_timeouts" toml:"query_timeouts"`
+	// ShutdownTimeout is how long in-flight requests and background tasks
+	// may take to finish after SIGINT or SIGTERM.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"API_SHUTDOWN_TIMEOUT"`
+}
+
+type Postgres struct {
+	Host            string   `yaml:"host" toml:"host" env:"POSTGRES_HOST"`
+	Port            int      `yaml:"port" toml:"port" env:"PGPORT"`
+	User            string   `yaml:"user" toml:"user" env:"POSTGRES_USER"`
+	Password        string   `yaml:"password" toml:"password" env:"POSTGRES_PASSWORD" secret:"true"`
+	DB              string   `yaml:"db" toml:"db" env:"POSTGRES_DB"`
+	SSLMode         string   `yaml:"sslmode" toml:"sslmode" env:"POSTGRES_SSLMODE"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"POSTGRES_MAX_OPEN_CONNS"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"POSTGRES_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"POSTGRES_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"POSTGRES_CONN_MAX_IDLE_TIME"`
+}
+
+// DSN returns the connection string of the database.
+func (p *Postgres) DSN() string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		p.Host,
+		p.User,
+		p.Password,
+		p.DB,
+		p.Port,
+		p.SSLMode,
+	)
+}
+
+type Logs struct {
+	// Output is file or stdout.
+	Output string `yaml:"output" toml:"output" env:"LOGS_OUTPUT"`
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOGS_LEVEL"`
+	Dir   string `yaml:"dir" toml:"dir" env:"LOGS_DIR"`
+	File  string `yaml:"file" toml:"file" env:"LOGS_FILE"`
+	// The log file is rotated when it reaches MaxSize. At most MaxBackups
+	// rotated files younger than MaxAge are kept, zero keeps them all.
+	MaxSize    Size     `yaml:"max_size" toml:"max_size" env:"LOGS_MAX_SIZE"`
+	MaxBackups int      `yaml:"max_backups" toml:"max_backups" env:"LOGS_MAX_BACKUPS"`
+	MaxAge     Duration `yaml:"max_age" toml:"max_age" env:"LOGS_MAX_AGE"`
+	Compress   bool     `yaml:"compress" toml:"compress" env:"LOGS_COMPRESS"`
+}
+
+type Auth struct {
+	TokenSecret string `yaml:"token_secret" toml:"token_secret" env:"TOKEN_SECRET" secret:"true"`
+}
+
+type Mail struct {
+	// Mailer is smtp or file.
+	Mailer string `yaml:"mailer" toml:"mailer" env:"MAILER"`
+	// Dir is the directory of the file mailer, mail in the logs directory by default.
+	Dir  string `yaml:"dir" toml:"dir" env:"MAIL_DIR"`
+	From string `yaml:"from" toml:"from" env:"MAIL_FROM"`
+	SMTP SMTP   `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTP struct {
+	Address  string `yaml:"address" toml:"address" env:"SMTP_ADDRESS"`
+	Username string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type RateLimit struct {
+	// Store is memory or postgres.
+	Store string `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE"`
+	// The limits count requests of a user or, for anonymous requests, of a client IP.
+	// Routes like "POST /post" without their own limit in Routes share the default one.
+	DefaultBurst    int              `yaml:"default_burst" toml:"default_burst" env:"RATE_LIMIT_DEFAULT_BURST"`
+	DefaultInterval Duration         `yaml:"default_interval" toml:"default_interval" env:"RATE_LIMIT_DEFAULT_INTERVAL"`
+	Routes          map[string]Limit `yaml:"routes" toml:"routes"`
+	// The auth limit counts lookups of bearer tokens of a client IP,
+	// so floods of invalid tokens do not reach the database.
+	AuthBurst    int      `yaml:"auth_burst" toml:"auth_burst" env:"RATE_LIMIT_AUTH_BURST"`
+	AuthInterval Duration `yaml:"auth_interval" toml:"auth_interval" env:"RATE_LIMIT_AUTH_INTERVAL"`
+}
+
+// Limit is a token bucket that holds up to Burst requests and gets one more
+// request every Interval. Zero Burst disables the limit.
+type Limit struct {
+	Burst    int      `yaml:"burst" toml:"burst"`
+	Interval Duration `yaml:"interval" toml:"interval"`
+}
+
+type Events struct {
+	// Sink is webhook or log.
+	Sink string `yaml:"sink" toml:"sink" env:"EVENT_SINK"`
+}
+
+type Health struct {
+	// Token protects the detailed /health report when it is not empty.
+	Token string `yaml:"token" toml:"token" env:"HEALTH_TOKEN" secret:"true"`
+	// Timeout is how long a dependency check may take.
+	Timeout Duration `yaml:"timeout" toml:"timeout" env:"HEALTH_TIMEOUT"`
+}
+
+type Tracing struct {
+	// Exporter is none, stdout or otlp. Spans are created with every exporter,
+	// so the logs always have trace ids.
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	// Endpoint is the URL of the OTLP/HTTP collector.
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+func Default() *Config {
+	return &Config{
+		API: API{
+			Address:           ":8000",
+			ReadTimeout:       Duration(30 * time.Second),
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			MaxHeaderSize:     1 << 20,
+			QueryTimeout:      Duration(10 * time.Second),
+			ShutdownTimeout:   Duration(20 * time.Second),
+		},
+		Postgres: Postgres{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+		},
+		Logs: Logs{
+			Output:     "file",
+			Level:      "info",
+			Dir:        "logs",
+			File:       "forum.log",
+			MaxSize:    100 << 20,
+			MaxBackups: 5,
+			MaxAge:     Duration(30 * 24 * time.Hour),
+		},
+		Mail: Mail{
+			Mailer: "file",
+		},
+		RateLimit: RateLimit{
+			Store:           "memory",
+			DefaultBurst:    120,
+			DefaultInterval: Duration(500 * time.Millisecond),
+			Routes: map[string]Limit{
+				"POST /user":                 {Burst: 5, Interval: Duration(time.Minute)},
+				"POST /post":                 {Burst: 5, Interval: Duration(30 * time.Second)},
+				"PUT /post/:id":              {Burst: 10, Interval: Duration(10 * time.Second)},
+				"POST /message":              {Burst: 20, Interval: Duration(3 * time.Second)},
+				"PUT /message/:id":           {Burst: 10, Interval: Duration(5 * time.Second)},
+				"POST /chat":                 {Burst: 5, Interval: Duration(time.Minute)},
+				"POST /auth/login":           {Burst: 10, Interval: Duration(6 * time.Second)},
+				"POST /auth/login/2fa":       {Burst: 10, Interval: Duration(6 * time.Second)},
+				"POST /auth/password/forgot": {Burst: 3, Interval: Duration(time.Minute)},
+				"POST /auth/verify/resend":   {Burst: 3, Interval: Duration(time.Minute)},
+				"POST /hooks/:token":         {Burst: 30, Interval: Duration(2 * time.Second)},
+			},
+			AuthBurst:    600,
+			AuthInterval: Duration(100 * time.Millisecond),
+		},
+		Events: Events{
+			Sink: "webhook",
+		},
+		Health: Health{
+			Timeout: Duration(2 * time.Second),
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "forum",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load reads the configuration from the defaults, the file if path is not empty
+// and the environment, and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, fmt.Errorf("cfg.readFile(%q): %w", path, err)
+		}
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return nil, fmt.Errorf("cfg.readEnv(): %w", err)
+	}
+
+	if cfg.Mail.Dir == "" {
+		cfg.Mail.Dir = filepath.Join(cfg.Logs.Dir, "mail")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, c)
+	case ".toml":
+		err = toml.Unmarshal(content, c)
+	default:
+		return ErrUnknownFormat
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse config file: %w", err)
+	}
+
+	return nil
+}
+
+// Validate returns all problems of the configuration joined in one error.
+func (c *Config) Validate() error {
+	var errs []error
+
+	require := func(value, name, env string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s (%s) is required", name, env))
+		}
+	}
+
+	oneOf := func(value, name, env string, allowed ...string) {
+		for _, option := range allowed {
+			if value == option {
+				return
+			}
+		}
+
+		errs = append(errs, fmt.Errorf(
+			"%s (%s) must be one of %s, got %q",
+			name,
+			env,
+			strings.Join(allowed, ", "),
+			value,
+		))
+	}
+
+	require(c.API.Address, "api.address", "API_ADDRESS")
+	require(c.Postgres.Host, "postgres.host", "POSTGRES_HOST")
+	require(c.Postgres.User, "postgres.user", "POSTGRES_USER")
+	require(c.Postgres.DB, "postgres.db", "POSTGRES_DB")
+	require(c.Logs.Dir, "logs.dir", "LOGS_DIR")
+	require(c.Auth.TokenSecret, "auth.token_secret", "TOKEN_SECRET")
+
+	if c.API.QueryTimeout <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"api.query_timeout (API_QUERY_TIMEOUT) must be positive, got %s",
+			time.Duration(c.API.QueryTimeout),
+		))
+	}
+
+	checkRoute := func(route, name string) {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf(
+				"%s route %q must look like \"GET /post/:id\"",
+				name,
+				route,
+			))
+		}
+	}
+
+	for route, timeout := range c.API.QueryTimeouts {
+		checkRoute(route, "api.query_timeouts")
+
+		if timeout < 0 {
+			errs = append(errs, fmt.Errorf(
+				"api.query_timeouts of %q must not be negative, got %s",
+				route,
+				time.Duration(timeout),
+			))
+		}
+	}
+
+	if c.API.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"api.shutdown_timeout (API_SHUTDOWN_TIMEOUT) must be positive, got %s",
+			time.Duration(c.API.ShutdownTimeout),
+		))
+	}
+
+	if c.Postgres.Port < 1 || c.Postgres.Port > 65535 {
+		errs = append(errs, fmt.Errorf("postgres.port (PGPORT) is invalid: %d", c.Postgres.Port))
+	}
+
+	if c.Postgres.MaxOpenConns < 1 {
+		errs = append(errs, fmt.Errorf(
+			"postgres.max_open_conns (POSTGRES_MAX_OPEN_CONNS) must be positive, got %d",
+			c.Postgres.MaxOpenConns,
+		))
+	}
+
+	if c.Postgres.MaxIdleConns < 0 || c.Postgres.MaxIdleConns > c.Postgres.MaxOpenConns {
+		errs = append(errs, fmt.Errorf(
+			"postgres.max_idle_conns (POSTGRES_MAX_IDLE_CONNS) must be between 0 and %d, got %d",
+			c.Postgres.MaxOpenConns,
+			c.Postgres.MaxIdleConns,
+		))
+	}
+
+	if c.Health.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"health.timeout (HEALTH_TIMEOUT) must be positive, got %s",
+			time.Duration(c.Health.Timeout),
+		))
+	}
+
+	oneOf(c.Logs.Output, "logs.output", "LOGS_OUTPUT", "file", "stdout")
+	if c.Logs.Output == "file" {
+		require(c.Logs.File, "logs.file", "LOGS_FILE")
+
+		if c.Logs.MaxSize < 1<<20 {
+			errs = append(errs, fmt.Errorf(
+				"logs.max_size (LOGS_MAX_SIZE) must be at least 1MiB, got %d bytes",
+				c.Logs.MaxSize,
+			))
+		}
+
+		if c.Logs.MaxBackups < 0 || c.Logs.MaxAge < 0 {
+			errs = append(errs, errors.New(
+				"logs.max_backups (LOGS_MAX_BACKUPS) and logs.max_age (LOGS_MAX_AGE) "+
+					"must not be negative",
+			))
+		}
+	}
+
+	oneOf(c.Logs.Level, "logs.level", "LOGS_LEVEL", "debug", "info", "warn", "error")
+
+	oneOf(c.Mail.Mailer, "mail.mailer", "MAILER", "file", "smtp")
+	if c.Mail.Mailer == "smtp" {
+		require(c.Mail.SMTP.Address, "mail.smtp.address", "SMTP_ADDRESS")
+		require(c.Mail.From, "mail.from", "MAIL_FROM")
+	}
+
+	oneOf(c.RateLimit.Store, "rate_limit.store", "RATE_LIMIT_STORE", "memory", "postgres")
+	if c.RateLimit.DefaultBurst < 0 {
+		errs = append(errs, fmt.Errorf(
+			"rate_limit.default_burst (RATE_LIMIT_DEFAULT_BURST) must not be negative, got %d",
+			c.RateLimit.DefaultBurst,
+		))
+	}
+
+	if c.RateLimit.DefaultBurst > 0 && c.RateLimit.DefaultInterval <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"rate_limit.default_interval (RATE_LIMIT_DEFAULT_INTERVAL) must be positive, got %s",
+			time.Duration(c.RateLimit.DefaultInterval),
+		))
+	}
+
+	if c.RateLimit.AuthBurst < 0 {
+		errs = append(errs, fmt.Errorf(
+			"rate_limit.auth_burst (RATE_LIMIT_AUTH_BURST) must not be negative, got %d",
+			c.RateLimit.AuthBurst,
+		))
+	}
+
+	if c.RateLimit.AuthBurst > 0 && c.RateLimit.AuthInterval <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"rate_limit.auth_interval (RATE_LIMIT_AUTH_INTERVAL) must be positive, got %s",
+			time.Duration(c.RateLimit.AuthInterval),
+		))
+	}
+
+	for route, limit := range c.RateLimit.Routes {
+		checkRoute(route, "rate_limit.routes")
+
+		if limit.Burst < 0 {
+			errs = append(errs, fmt.Errorf(
+				"rate_limit.routes burst of %q must not be negative, got %d",
+				route,
+				limit.Burst,
+			))
+		}
+
+		if limit.Burst > 0 && limit.Interval <= 0 {
+			errs = append(errs, fmt.Errorf(
+				"rate_limit.routes interval of %q must be positive, got %s",
+				route,
+				time.Duration(limit.Interval),
+			))
+		}
+	}
+	oneOf(c.Events.Sink, "events.sink", "EVENT_SINK", "webhook", "log")
+
+	oneOf(c.Tracing.Exporter, "tracing.exporter", "TRACING_EXPORTER", "none", "stdout", "otlp")
+	if c.Tracing.Exporter == "otlp" {
+		require(c.Tracing.Endpoint, "tracing.endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	require(c.Tracing.ServiceName, "tracing.service_name", "OTEL_SERVICE_NAME")
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf(
+			"tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1, got %g",
+			c.Tracing.SampleRatio,
+		))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with the secrets hidden,
+// so it can be logged.
+func (c *Config) Redacted() Config {
+	cfg := *c
+	walkFields(&cfg, func(field field) error {
+		if field.secret && field.value.String() != "" {
+			field.value.SetString(redacted)
+		}
+
+		return nil
+	})
+
+	return cfg
+}
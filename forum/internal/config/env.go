@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+type field struct {
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// walkFields calls fn for every field of the configuration that has an env tag.
+func walkFields(cfg *Config, fn func(field field) error) error {
+	return walk(reflect.ValueOf(cfg).Elem(), fn)
+}
+
+func walk(value reflect.Value, fn func(field field) error) error {
+	for i := range value.NumField() {
+		structField := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		env, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if fieldValue.Kind() == reflect.Struct {
+				if err := walk(fieldValue, fn); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		err := fn(field{
+			env:    env,
+			secret: structField.Tag.Get("secret") == "true",
+			value:  fieldValue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readEnv overrides fields with the environment variables that are set and not empty.
+func (c *Config) readEnv() error {
+	return walkFields(c, func(field field) error {
+		raw := os.Getenv(field.env)
+		if raw == "" {
+			return nil
+		}
+
+		if err := setValue(field.value, raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.env, err)
+		}
+
+		return nil
+	})
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("strconv.Atoi(%q): %w", raw, err)
+		}
+
+		value.SetInt(int64(number))
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("strconv.ParseBool(%q): %w", raw, err)
+		}
+
+		value.SetBool(flag)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("strconv.ParseFloat(%q): %w", raw, err)
+		}
+
+		value.SetFloat(number)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSize = errors.New("size must be a number of bytes with an optional unit")
+
+// Duration is a time.Duration written as "30s" or "1h30m" in files and variables.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("time.ParseDuration(%q): %w", text, err)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Size is a number of bytes written as "512", "64KB" or "1MiB" in files and variables.
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	// Longer suffixes go first, so "KiB" is not taken for "B".
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1_000},
+	{"MB", 1_000_000},
+	{"GB", 1_000_000_000},
+	{"B", 1},
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value = strings.TrimSpace(number)
+			multiplier = unit.bytes
+
+			break
+		}
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return fmt.Errorf("cannot parse size %q: %w", text, ErrInvalidSize)
+	}
+
+	*s = Size(number * multiplier)
+
+	return nil
+}
+
+func (s Size) MarshalText() ([]byte, error) {
+	for _, unit := range sizeUnits[:3] {
+		if s != 0 && int64(s)%unit.bytes == 0 && int64(s)/unit.bytes < 1<<10 {
+			return []byte(fmt.Sprintf("%d%s", int64(s)/unit.bytes, unit.suffix)), nil
+		}
+	}
+
+	return []byte(strconv.FormatInt(int64(s), 10)), nil
+}
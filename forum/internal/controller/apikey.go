@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) CreateAPIKey(c *gin.Context) {
+	user := currentUser(c)
+
+	var request struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid api key json: %s", err), "method", "ctrl.CreateAPIKey")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	apiKey, err := ctrl.authenticator.CreateAPIKey(
+		c.Request.Context(),
+		user,
+		request.Name,
+		request.Scopes,
+		request.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.CreateAPIKey(%d): %s", user.ID, err),
+			"method",
+			"ctrl.CreateAPIKey",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, apiKey)
+}
+
+func (ctrl *Controller) GetAPIKeys(c *gin.Context) {
+	user := currentUser(c)
+
+	apiKeys, err := ctrl.authenticator.GetAPIKeys(c.Request.Context(), user)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.authenticator.GetAPIKeys(%d): %s", user.ID, err),
+			"method",
+			"ctrl.GetAPIKeys",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, apiKeys)
+}
+
+func (ctrl *Controller) RevokeAPIKey(c *gin.Context) {
+	user := currentUser(c)
+
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid api key id: %s", err), "method", "ctrl.RevokeAPIKey")
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.authenticator.RevokeAPIKey(c.Request.Context(), user, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no active api key with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.RevokeAPIKey(%d): %s", id, err),
+			"method",
+			"ctrl.RevokeAPIKey",
+		)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully revoked"})
+}
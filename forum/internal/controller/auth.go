@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) VerifyEmail(c *gin.Context) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid verification json: %s", err), "method", "ctrl.VerifyEmail")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	user, err := ctrl.verifier.Verify(c.Request.Context(), request.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			c.IndentedJSON(
+				http.StatusBadRequest,
+				gin.H{"error": "token is invalid, expired or already used"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.verifier.Verify(): %s", err),
+			"method",
+			"ctrl.VerifyEmail",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, user)
+}
+
+func (ctrl *Controller) ResendVerification(c *gin.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid verification json: %s", err),
+			"method",
+			"ctrl.ResendVerification",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.verifier.Resend(c.Request.Context(), request.Email); err != nil {
+		if errors.Is(err, auth.ErrTooManyRequests) {
+			c.IndentedJSON(
+				http.StatusTooManyRequests,
+				gin.H{"error": "verification email was sent recently, try again later"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.verifier.Resend(%q): %s", request.Email, err),
+			"method",
+			"ctrl.ResendVerification",
+		)
+		c.Abort()
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{"message": "if this email is registered and not verified, a new token was sent"},
+	)
+}
+
+func (ctrl *Controller) Login(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid login json: %s", err), "method", "ctrl.Login")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	result, err := ctrl.authenticator.Login(
+		c.Request.Context(),
+		request.Email,
+		request.Password,
+		c.ClientIP(),
+	)
+	if err != nil {
+		if throttleErr := (*auth.ThrottleError)(nil); errors.As(err, &throttleErr) {
+			abortTooManyAttempts(c, throttleErr)
+		} else if errors.Is(err, auth.ErrInvalidCredentials) {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.Login(%q): %s", request.Email, err),
+			"method",
+			"ctrl.Login",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, result)
+}
+
+func (ctrl *Controller) LoginTwoFactor(c *gin.Context) {
+	var request struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid two-factor login json: %s", err),
+			"method",
+			"ctrl.LoginTwoFactor",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	result, err := ctrl.authenticator.LoginTwoFactor(
+		c.Request.Context(),
+		request.Challenge,
+		request.Code,
+		c.ClientIP(),
+	)
+	if err != nil {
+		if throttleErr := (*auth.ThrottleError)(nil); errors.As(err, &throttleErr) {
+			abortTooManyAttempts(c, throttleErr)
+		} else if errors.Is(err, auth.ErrInvalidToken) {
+			c.IndentedJSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "challenge is invalid or expired, log in again"},
+			)
+		} else if errors.Is(err, auth.ErrInvalidCode) {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid two-factor code"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.LoginTwoFactor(): %s", err),
+			"method",
+			"ctrl.LoginTwoFactor",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, result)
+}
+
+func (ctrl *Controller) Logout(c *gin.Context) {
+	token, ok := bearerToken(c)
+	if !ok {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.authenticator.Logout(c.Request.Context(), token); err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.authenticator.Logout(): %s", err),
+			"method",
+			"ctrl.Logout",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully logged out"})
+}
+
+func (ctrl *Controller) ForgotPassword(c *gin.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid forgot password json: %s", err),
+			"method",
+			"ctrl.ForgotPassword",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	// The token is issued in the background, so the response and its timing
+	// do not reveal whether the email is registered.
+	ctx := context.WithoutCancel(c.Request.Context())
+	logger := ctrl.log(c)
+	ctrl.runInBackground(func() {
+		if err := ctrl.authenticator.ForgotPassword(ctx, request.Email); err != nil {
+			logger.Error(
+				fmt.Sprintf("ctrl.authenticator.ForgotPassword(%q): %s", request.Email, err),
+				"method",
+				"ctrl.ForgotPassword",
+			)
+		}
+	})
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{"message": "if this email is registered, a password reset token was sent"},
+	)
+}
+
+func (ctrl *Controller) ResetPassword(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid reset password json: %s", err),
+			"method",
+			"ctrl.ResetPassword",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.authenticator.ResetPassword(
+		c.Request.Context(),
+		request.Token,
+		request.Password,
+	); err != nil {
+		if errors.Is(err, auth.ErrInvalidPassword) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if errors.Is(err, auth.ErrInvalidToken) {
+			c.IndentedJSON(
+				http.StatusBadRequest,
+				gin.H{"error": "token is invalid, expired or already used"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.ResetPassword(): %s", err),
+			"method",
+			"ctrl.ResetPassword",
+		)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password was changed, please log in again"})
+}
+
+// bearerToken returns the token from the "Authorization: Bearer <token>" header.
+func bearerToken(c *gin.Context) (string, bool) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+func (ctrl *Controller) Unlock(c *gin.Context) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid unlock json: %s", err), "method", "ctrl.Unlock")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.authenticator.Unlock(c.Request.Context(), request.Token); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "token is invalid or expired"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.Unlock(): %s", err),
+			"method",
+			"ctrl.Unlock",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "account is unlocked"})
+}
+
+func (ctrl *Controller) UnlockUser(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.UnlockUser")
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.authenticator.UnlockUser(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.UnlockUser(%d): %s", id, err),
+			"method",
+			"ctrl.UnlockUser",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": "account is unlocked"})
+}
+
+// abortTooManyAttempts tells the client when it may try to log in again.
+func abortTooManyAttempts(c *gin.Context, err *auth.ThrottleError) {
+	c.Header("Retry-After", strconv.Itoa(ceilSeconds(err.RetryAfter)))
+	c.IndentedJSON(
+		http.StatusTooManyRequests,
+		gin.H{"error": "too many failed login attempts, try again later"},
+	)
+}
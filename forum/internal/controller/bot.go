@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LLIEPJIOK/forum/internal/bot"
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+func (ctrl *Controller) AddBot(c *gin.Context) {
+	var request struct {
+		Nickname   string `json:"nickname"`
+		WebhookURL string `json:"webhook_url"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid bot json: %s", err), "method", "ctrl.AddBot")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	if request.Nickname == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "bot nickname is empty"})
+		c.Abort()
+		return
+	}
+
+	newBot, secret, err := ctrl.bots.AddWebhookBot(request.Nickname, request.WebhookURL)
+	if err != nil {
+		if errors.Is(err, bot.ErrInvalidWebhookURL) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if errors.Is(err, database.ErrUniqueConstraint) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": "bot with this nickname already exists"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.bots.AddWebhookBot(%q): %s", request.Nickname, err),
+			"method",
+			"ctrl.AddBot",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{"bot": newBot, "secret": secret})
+}
+
+func (ctrl *Controller) GetAllBots(c *gin.Context) {
+	bots, err := ctrl.db.GetAllBots(c.Request.Context())
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.GetAllBots(): %s", err),
+			"method",
+			"ctrl.GetAllBots",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, bots)
+}
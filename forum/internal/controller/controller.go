@@ -1,76 +1,292 @@
 package controller
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/LLIEPJIOK/forum/internal/auth"
 	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/health"
+	"github.com/LLIEPJIOK/forum/internal/ratelimit"
+	"github.com/LLIEPJIOK/forum/internal/webhook"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type DBInterface interface {
-	AddUser(user *database.User) error
-	GetUserByID(id uint) (*database.User, error)
-	GetAllUsers() ([]*database.User, error)
-	UpdateUser(user *database.User) (*database.User, error)
-	DeleteUser(id uint) error
-
-	AddPost(post *database.Post) error
-	GetPost(id uint) (*database.Post, error)
-	GetAllPosts() ([]*database.Post, error)
-	UpdatePost(post *database.Post) (*database.Post, error)
-	DeletePost(id uint) error
-
-	AddMessage(message *database.Message) error
-	GetMessage(id uint) (*database.Message, error)
-	GetAllMessages() ([]*database.Message, error)
-	UpdateMessage(message *database.Message) (*database.Message, error)
-	DeleteMessage(id uint) error
-
-	AddChat(chat *database.Chat) error
-	GetChat(id uint) (*database.Chat, error)
-	GetAllChats() ([]*database.Chat, error)
-	UpdateChat(chat *database.Chat) (*database.Chat, error)
-	DeleteChat(id uint) error
+	AddUser(ctx context.Context, user *database.User) error
+	GetUserByID(ctx context.Context, id uint) (*database.User, error)
+	GetAllUsers(ctx context.Context) ([]*database.User, error)
+	UpdateUser(ctx context.Context, user *database.User) (*database.User, error)
+	DeleteUser(ctx context.Context, id uint) error
+
+	AddPost(ctx context.Context, post *database.Post) error
+	GetPost(ctx context.Context, id uint) (*database.Post, error)
+	GetAllPosts(ctx context.Context) ([]*database.Post, error)
+	UpdatePost(ctx context.Context, post *database.Post) (*database.Post, error)
+	DeletePost(ctx context.Context, id uint) error
+
+	AddMessage(ctx context.Context, message *database.Message) error
+	GetMessage(ctx context.Context, id uint) (*database.Message, error)
+	GetAllMessages(ctx context.Context) ([]*database.Message, error)
+	UpdateMessage(ctx context.Context, message *database.Message) (*database.Message, error)
+	DeleteMessage(ctx context.Context, id uint) error
+
+	AddChat(ctx context.Context, chat *database.Chat) error
+	GetChat(ctx context.Context, id uint) (*database.Chat, error)
+	GetAllChats(ctx context.Context) ([]*database.Chat, error)
+	UpdateChat(ctx context.Context, chat *database.Chat) (*database.Chat, error)
+	DeleteChat(ctx context.Context, id uint) error
+	AddUserToChat(ctx context.Context, user *database.User, chat *database.Chat) error
+	IsChatMember(ctx context.Context, chatID, userID uint) (bool, error)
+
+	GetUserMentions(ctx context.Context, userID uint) ([]*database.Mention, error)
+	GetPostsByTag(ctx context.Context, name string) ([]*database.Post, error)
+
+	GetNotifications(
+		ctx context.Context,
+		userID uint,
+		unreadOnly bool,
+	) ([]*database.Notification, error)
+	MarkNotificationRead(
+		ctx context.Context,
+		userID, id uint,
+	) (*database.Notification, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uint) error
+	GetNotificationPreference(
+		ctx context.Context,
+		userID uint,
+	) (*database.NotificationPreference, error)
+	SetNotificationPreference(
+		ctx context.Context,
+		preference *database.NotificationPreference,
+	) (*database.NotificationPreference, error)
+
+	GetAllTwoFactorPolicies(ctx context.Context) ([]*database.TwoFactorPolicy, error)
+	SetTwoFactorPolicy(
+		ctx context.Context,
+		policy *database.TwoFactorPolicy,
+	) (*database.TwoFactorPolicy, error)
+
+	GetAllBots(ctx context.Context) ([]database.Bot, error)
+
+	GetAllWebhookSubscriptions(ctx context.Context) ([]database.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id uint) error
+	GetWebhookDeliveries(
+		ctx context.Context,
+		subscriptionID uint,
+		limit int,
+	) ([]database.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id uint) (*database.WebhookDelivery, error)
+
+	GetChatIncomingWebhooks(ctx context.Context, chatID uint) ([]database.IncomingWebhook, error)
+	SetIncomingWebhookDisabled(
+		ctx context.Context,
+		id uint,
+		disabled bool,
+	) (*database.IncomingWebhook, error)
+}
+
+type NotificationSubscriber interface {
+	Subscribe(userID uint) (<-chan *database.Notification, func())
+}
+
+type Verifier interface {
+	Send(ctx context.Context, user *database.User) error
+	Resend(ctx context.Context, email string) error
+	Verify(ctx context.Context, token string) (*database.User, error)
+}
+
+type Authenticator interface {
+	Login(ctx context.Context, email, password, ip string) (*auth.LoginResult, error)
+	LoginTwoFactor(ctx context.Context, challenge, code, ip string) (*auth.LoginResult, error)
+	Authenticate(ctx context.Context, token string) (*database.User, error)
+	Logout(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+
+	EnrollTwoFactor(ctx context.Context, user *database.User) (*auth.TwoFactorEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, user *database.User, code string) ([]string, error)
+	MissingTwoFactor(ctx context.Context, user *database.User) (bool, error)
+	Unlock(ctx context.Context, token string) error
+	UnlockUser(ctx context.Context, userID uint) error
+
+	CreateAPIKey(
+		ctx context.Context,
+		user *database.User,
+		name string,
+		scopes []string,
+		expiresAt *time.Time,
+	) (*auth.NewAPIKey, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*database.User, *database.APIKey, error)
+	GetAPIKeys(ctx context.Context, user *database.User) ([]database.APIKey, error)
+	RevokeAPIKey(ctx context.Context, user *database.User, id uint) error
+}
+
+type Bots interface {
+	Dispatch(ctx context.Context, message *database.Message) error
+	AddWebhookBot(nickname, webhookURL string) (*database.Bot, string, error)
+}
+
+type Webhooks interface {
+	Subscribe(url string, events []string) (*database.WebhookSubscription, string, error)
+}
+
+type IncomingWebhooks interface {
+	Create(ctx context.Context, chatID uint, name string) (*database.IncomingWebhook, string, error)
+	Rotate(id uint) (*database.IncomingWebhook, string, error)
+	Post(ctx context.Context, token string, payload *webhook.Payload) (*database.Message, error)
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, route, client string) (*ratelimit.Result, error)
+	AllowAuth(ctx context.Context, client string) (*ratelimit.Result, error)
+}
+
+type Health interface {
+	Ready(ctx context.Context) error
+	Report(ctx context.Context) *health.Report
+	Authorized(token string) bool
+}
+
+type Metrics interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+	StreamOpened()
+	StreamClosed()
+	UserRegistered()
+	PostCreated()
+	MessageSent()
+	Handler() http.Handler
 }
 
 type Controller struct {
-	db     DBInterface
-	logger *slog.Logger
+	db            DBInterface
+	notifications NotificationSubscriber
+	verifier      Verifier
+	authenticator Authenticator
+	bots          Bots
+	webhooks      Webhooks
+	incoming      IncomingWebhooks
+	limiter       RateLimiter
+	health        Health
+	metrics       Metrics
+	timeouts      QueryTimeouts
+	logger        *slog.Logger
+
+	// background tracks the tasks that handlers leave running after the response.
+	background sync.WaitGroup
 }
 
-func New(db DBInterface, logger *slog.Logger) *Controller {
+func New(
+	db DBInterface,
+	notifications NotificationSubscriber,
+	verifier Verifier,
+	authenticator Authenticator,
+	bots Bots,
+	webhooks Webhooks,
+	incoming IncomingWebhooks,
+	limiter RateLimiter,
+	health Health,
+	metrics Metrics,
+	timeouts QueryTimeouts,
+	logger *slog.Logger,
+) *Controller {
 	return &Controller{
-		db:     db,
-		logger: logger,
+		db:            db,
+		notifications: notifications,
+		verifier:      verifier,
+		authenticator: authenticator,
+		bots:          bots,
+		webhooks:      webhooks,
+		incoming:      incoming,
+		limiter:       limiter,
+		health:        health,
+		metrics:       metrics,
+		timeouts:      timeouts,
+		logger:        logger,
+	}
+}
+
+// runInBackground runs fn after the handler returns. Wait waits for it on shutdown.
+func (ctrl *Controller) runInBackground(fn func()) {
+	ctrl.background.Add(1)
+
+	go func() {
+		defer ctrl.background.Done()
+
+		fn()
+	}()
+}
+
+// Wait blocks until the background tasks of handled requests finish or ctx is done.
+func (ctrl *Controller) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		ctrl.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
+// userInput is the user sent by clients. Unlike database.User, it takes the password.
+type userInput struct {
+	Nickname string  `json:"nickname"`
+	Email    *string `json:"email"`
+	Password string  `json:"password"`
+}
+
 func (ctrl *Controller) AddUser(c *gin.Context) {
-	var user database.User
-	if err := c.BindJSON(&user); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid user json: %s", err), "method", "ctrl.AddUser")
+	var input userInput
+	if err := c.BindJSON(&input); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid user json: %s", err), "method", "ctrl.AddUser")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.AddUser(&user); err != nil {
+	user := database.User{
+		Nickname: input.Nickname,
+		Email:    input.Email,
+	}
+
+	hash, err := auth.HashPassword(input.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidPassword) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(fmt.Sprintf("auth.HashPassword(): %s", err), "method", "ctrl.AddUser")
+		c.Abort()
+		return
+	}
+	user.HashPassword = hash
+
+	if err := ctrl.db.AddUser(c.Request.Context(), &user); err != nil {
 		if errors.Is(err, database.ErrUniqueConstraint) {
 			c.IndentedJSON(
 				http.StatusBadRequest,
 				gin.H{"error": "user with this email already registered"},
 			)
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
-			fmt.Sprintf("ctrl.db.AddUser(%#v): %s", &user, err),
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.AddUser(%q): %s", user.Nickname, err),
 			"method",
 			"ctrl.AddUser",
 		)
@@ -78,6 +294,16 @@ func (ctrl *Controller) AddUser(c *gin.Context) {
 		return
 	}
 
+	ctrl.metrics.UserRegistered()
+
+	if err := ctrl.verifier.Send(c.Request.Context(), &user); err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.verifier.Send(%d): %s", user.ID, err),
+			"method",
+			"ctrl.AddUser",
+		)
+	}
+
 	c.IndentedJSON(http.StatusOK, user)
 }
 
@@ -85,21 +311,21 @@ func (ctrl *Controller) GetUser(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.GetUser")
+		ctrl.log(c).Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.GetUser")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		c.Abort()
 		return
 	}
 
-	user, err := ctrl.db.GetUserByID(uint(id))
+	user, err := ctrl.db.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Info(
+		ctrl.log(c).Info(
 			fmt.Sprintf("ctrl.db.GetUserByID(uint(%d)): %s", id, err),
 			"method",
 			"ctrl.GetUser",
@@ -112,14 +338,14 @@ func (ctrl *Controller) GetUser(c *gin.Context) {
 }
 
 func (ctrl *Controller) GetAllUsers(c *gin.Context) {
-	users, err := ctrl.db.GetAllUsers()
+	users, err := ctrl.db.GetAllUsers(c.Request.Context())
 	if err != nil {
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.GetAllUsers(): %s", err),
 			"method",
 			"ctrl.GetAllUsers",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -131,22 +357,47 @@ func (ctrl *Controller) UpdateUser(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.GetUser")
+		ctrl.log(c).Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.GetUser")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		c.Abort()
 		return
 	}
 
-	var user database.User
-	if err := c.BindJSON(&user); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid user json: %s", err), "method", "ctrl.UpdateUser")
+	if !ctrl.requireOwner(c, uint(id), database.RoleAdmin) {
+		return
+	}
+
+	var input userInput
+	if err := c.BindJSON(&input); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid user json: %s", err), "method", "ctrl.UpdateUser")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
+	user := database.User{
+		Nickname: input.Nickname,
+		Email:    input.Email,
+	}
+
+	if input.Password != "" {
+		hash, err := auth.HashPassword(input.Password)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidPassword) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				serverError(c, err)
+			}
+
+			ctrl.log(c).Info(fmt.Sprintf("auth.HashPassword(): %s", err), "method", "ctrl.UpdateUser")
+			c.Abort()
+			return
+		}
+		user.HashPassword = hash
+	}
+
 	user.ID = uint(id)
-	updatedUser, err := ctrl.db.UpdateUser(&user)
+	updatedUser, err := ctrl.db.UpdateUser(c.Request.Context(), &user)
 	if err != nil {
 		if errors.Is(err, database.ErrUniqueConstraint) {
 			c.IndentedJSON(
@@ -156,11 +407,11 @@ func (ctrl *Controller) UpdateUser(c *gin.Context) {
 		} else if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
-			fmt.Sprintf("ctrl.db.UpdateUser(%#v): %s", &user, err),
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.UpdateUser(%d): %s", user.ID, err),
 			"method",
 			"ctrl.UpdateUser",
 		)
@@ -168,6 +419,16 @@ func (ctrl *Controller) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if input.Email != nil && updatedUser.VerifiedAt == nil {
+		if err := ctrl.verifier.Send(c.Request.Context(), updatedUser); err != nil {
+			ctrl.log(c).Error(
+				fmt.Sprintf("ctrl.verifier.Send(%d): %s", updatedUser.ID, err),
+				"method",
+				"ctrl.UpdateUser",
+			)
+		}
+	}
+
 	c.IndentedJSON(http.StatusOK, updatedUser)
 }
 
@@ -175,19 +436,23 @@ func (ctrl *Controller) DeleteUser(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.DeleteUser")
+		ctrl.log(c).Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.DeleteUser")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.DeleteUser(uint(id)); err != nil {
-		ctrl.logger.Error(
+	if !ctrl.requireOwner(c, uint(id), database.RoleAdmin) {
+		return
+	}
+
+	if err := ctrl.db.DeleteUser(c.Request.Context(), uint(id)); err != nil {
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.DeleteUser(%d): %s", id, err),
 			"method",
 			"ctrl.DeleteUser",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -198,20 +463,24 @@ func (ctrl *Controller) DeleteUser(c *gin.Context) {
 func (ctrl *Controller) AddPost(c *gin.Context) {
 	var post database.Post
 	if err := c.BindJSON(&post); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid post json: %s", err), "method", "ctrl.AddPost")
+		ctrl.log(c).Info(fmt.Sprintf("invalid post json: %s", err), "method", "ctrl.AddPost")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.AddPost(&post); err != nil {
+	post.AuthorID = currentUser(c).ID
+
+	if err := ctrl.db.AddPost(c.Request.Context(), &post); err != nil {
 		if errors.Is(err, database.ErrForeignKeyConstraint) {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no such author with this id"})
+		} else if errors.Is(err, database.ErrUnverified) {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"error": "author email is not verified"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.AddPost(%#v): %s", &post, err),
 			"method",
 			"ctrl.AddPost",
@@ -220,28 +489,49 @@ func (ctrl *Controller) AddPost(c *gin.Context) {
 		return
 	}
 
+	ctrl.metrics.PostCreated()
+
 	c.IndentedJSON(http.StatusOK, post)
 }
 
+// requirePostOwner rejects the request unless the current user wrote the post
+// or moderates the forum.
+func (ctrl *Controller) requirePostOwner(c *gin.Context, id uint, method string) bool {
+	post, err := ctrl.db.GetPost(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no post with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(fmt.Sprintf("ctrl.db.GetPost(%d): %s", id, err), "method", method)
+		c.Abort()
+		return false
+	}
+
+	return ctrl.requireOwner(c, post.AuthorID, database.RoleModerator, database.RoleAdmin)
+}
+
 func (ctrl *Controller) GetPost(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.GetPost")
+		ctrl.log(c).Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.GetPost")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
 		c.Abort()
 		return
 	}
 
-	post, err := ctrl.db.GetPost(uint(id))
+	post, err := ctrl.db.GetPost(c.Request.Context(), uint(id))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no post with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Info(
+		ctrl.log(c).Info(
 			fmt.Sprintf("ctrl.db.GetPost(uint(%d)): %s", id, err),
 			"method",
 			"ctrl.GetPost",
@@ -254,14 +544,14 @@ func (ctrl *Controller) GetPost(c *gin.Context) {
 }
 
 func (ctrl *Controller) GetAllPosts(c *gin.Context) {
-	posts, err := ctrl.db.GetAllPosts()
+	posts, err := ctrl.db.GetAllPosts(c.Request.Context())
 	if err != nil {
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.GetAllUsers(): %s", err),
 			"method",
 			"ctrl.GetAllPosts",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -273,30 +563,34 @@ func (ctrl *Controller) UpdatePost(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.UpdatePost")
+		ctrl.log(c).Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.UpdatePost")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
 		c.Abort()
 		return
 	}
 
+	if !ctrl.requirePostOwner(c, uint(id), "ctrl.UpdatePost") {
+		return
+	}
+
 	var post database.Post
 	if err := c.BindJSON(&post); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid post json: %s", err), "method", "ctrl.UpdatePost")
+		ctrl.log(c).Info(fmt.Sprintf("invalid post json: %s", err), "method", "ctrl.UpdatePost")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
 	post.ID = uint(id)
-	updatedPost, err := ctrl.db.UpdatePost(&post)
+	updatedPost, err := ctrl.db.UpdatePost(c.Request.Context(), &post)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no post with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.UpdatePost(%#v): %s", &post, err),
 			"method",
 			"ctrl.UpdatePost",
@@ -312,19 +606,23 @@ func (ctrl *Controller) DeletePost(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.DeletePost")
+		ctrl.log(c).Info(fmt.Sprintf("invalid post id: %s", err), "method", "ctrl.DeletePost")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.DeletePost(uint(id)); err != nil {
-		ctrl.logger.Error(
+	if !ctrl.requirePostOwner(c, uint(id), "ctrl.DeletePost") {
+		return
+	}
+
+	if err := ctrl.db.DeletePost(c.Request.Context(), uint(id)); err != nil {
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.DeletePost(%d): %s", id, err),
 			"method",
 			"ctrl.DeletePost",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -335,20 +633,49 @@ func (ctrl *Controller) DeletePost(c *gin.Context) {
 func (ctrl *Controller) AddMessage(c *gin.Context) {
 	var message database.Message
 	if err := c.BindJSON(&message); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid message json: %s", err), "method", "ctrl.AddMessage")
+		ctrl.log(c).Info(fmt.Sprintf("invalid message json: %s", err), "method", "ctrl.AddMessage")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.AddMessage(&message); err != nil {
+	message.SenderID = currentUser(c).ID
+	// Only incoming webhooks show their messages under another name.
+	message.SenderName = ""
+
+	member, err := ctrl.db.IsChatMember(c.Request.Context(), message.ChatID, message.SenderID)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf(
+				"ctrl.db.IsChatMember(%d, %d): %s",
+				message.ChatID,
+				message.SenderID,
+				err,
+			),
+			"method",
+			"ctrl.AddMessage",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	if !member {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "you are not a member of this chat"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.db.AddMessage(c.Request.Context(), &message); err != nil {
 		if errors.Is(err, database.ErrForeignKeyConstraint) {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no such sender with this id or chat with this id"})
+		} else if errors.Is(err, database.ErrUnverified) {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"error": "sender email is not verified"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.AddMessage(%#v): %s", &message, err),
 			"method",
 			"ctrl.AddMessage",
@@ -357,28 +684,62 @@ func (ctrl *Controller) AddMessage(c *gin.Context) {
 		return
 	}
 
+	ctrl.metrics.MessageSent()
+
+	// Bots reply with their own messages, so the sender does not wait for them.
+	ctx := context.WithoutCancel(c.Request.Context())
+	logger := ctrl.log(c)
+	ctrl.runInBackground(func() {
+		if err := ctrl.bots.Dispatch(ctx, &message); err != nil {
+			logger.Error(
+				fmt.Sprintf("ctrl.bots.Dispatch(%d): %s", message.ID, err),
+				"method",
+				"ctrl.AddMessage",
+			)
+		}
+	})
+
 	c.IndentedJSON(http.StatusOK, message)
 }
 
+// requireMessageOwner rejects the request unless the current user sent the message
+// or moderates the forum.
+func (ctrl *Controller) requireMessageOwner(c *gin.Context, id uint, method string) bool {
+	message, err := ctrl.db.GetMessage(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no message with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(fmt.Sprintf("ctrl.db.GetMessage(%d): %s", id, err), "method", method)
+		c.Abort()
+		return false
+	}
+
+	return ctrl.requireOwner(c, message.SenderID, database.RoleModerator, database.RoleAdmin)
+}
+
 func (ctrl *Controller) GetMessage(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.GetMessage")
+		ctrl.log(c).Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.GetMessage")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
 		c.Abort()
 		return
 	}
 
-	message, err := ctrl.db.GetMessage(uint(id))
+	message, err := ctrl.db.GetMessage(c.Request.Context(), uint(id))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no message with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Info(
+		ctrl.log(c).Info(
 			fmt.Sprintf("ctrl.db.GetMessage(uint(%d)): %s", id, err),
 			"method",
 			"ctrl.GetMessage",
@@ -391,14 +752,14 @@ func (ctrl *Controller) GetMessage(c *gin.Context) {
 }
 
 func (ctrl *Controller) GetAllMessages(c *gin.Context) {
-	messages, err := ctrl.db.GetAllMessages()
+	messages, err := ctrl.db.GetAllMessages(c.Request.Context())
 	if err != nil {
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.GetAllMessages(): %s", err),
 			"method",
 			"ctrl.GetAllMessages",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -410,30 +771,34 @@ func (ctrl *Controller) UpdateMessage(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.UpdateMessage")
+		ctrl.log(c).Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.UpdateMessage")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
 		c.Abort()
 		return
 	}
 
+	if !ctrl.requireMessageOwner(c, uint(id), "ctrl.UpdateMessage") {
+		return
+	}
+
 	var message database.Message
 	if err := c.BindJSON(&message); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid message json: %s", err), "method", "ctrl.UpdateMessage")
+		ctrl.log(c).Info(fmt.Sprintf("invalid message json: %s", err), "method", "ctrl.UpdateMessage")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
 	message.ID = uint(id)
-	updatedMessage, err := ctrl.db.UpdateMessage(&message)
+	updatedMessage, err := ctrl.db.UpdateMessage(c.Request.Context(), &message)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no message with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.UpdateMessage(%#v): %s", &message, err),
 			"method",
 			"ctrl.UpdateMessage",
@@ -449,19 +814,23 @@ func (ctrl *Controller) DeleteMessage(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.DeleteMessage")
+		ctrl.log(c).Info(fmt.Sprintf("invalid message id: %s", err), "method", "ctrl.DeleteMessage")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.DeleteMessage(uint(id)); err != nil {
-		ctrl.logger.Error(
+	if !ctrl.requireMessageOwner(c, uint(id), "ctrl.DeleteMessage") {
+		return
+	}
+
+	if err := ctrl.db.DeleteMessage(c.Request.Context(), uint(id)); err != nil {
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.DeleteMessage(%d): %s", id, err),
 			"method",
 			"ctrl.DeleteMessage",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -472,15 +841,16 @@ func (ctrl *Controller) DeleteMessage(c *gin.Context) {
 func (ctrl *Controller) AddChat(c *gin.Context) {
 	var chat database.Chat
 	if err := c.BindJSON(&chat); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid chat json: %s", err), "method", "ctrl.AddChat")
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat json: %s", err), "method", "ctrl.AddChat")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.AddChat(&chat); err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
-		ctrl.logger.Error(
+	chat.OwnerID = &currentUser(c).ID
+	if err := ctrl.db.AddChat(c.Request.Context(), &chat); err != nil {
+		serverError(c, err)
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.AddChat(%#v): %s", &chat, err),
 			"method",
 			"ctrl.AddChat",
@@ -492,25 +862,58 @@ func (ctrl *Controller) AddChat(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, chat)
 }
 
+// requireChatOwner returns the chat if the current user owns it or is an admin.
+// Otherwise it rejects the request.
+func (ctrl *Controller) requireChatOwner(
+	c *gin.Context,
+	id uint,
+	method string,
+) (*database.Chat, bool) {
+	chat, err := ctrl.db.GetChat(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no chat with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(fmt.Sprintf("ctrl.db.GetChat(%d): %s", id, err), "method", method)
+		c.Abort()
+		return nil, false
+	}
+
+	// Chats created before owners have none and only admins manage them.
+	var ownerID uint
+	if chat.OwnerID != nil {
+		ownerID = *chat.OwnerID
+	}
+
+	if !ctrl.requireOwner(c, ownerID, database.RoleAdmin) {
+		return nil, false
+	}
+
+	return chat, true
+}
+
 func (ctrl *Controller) GetChat(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.GetChat")
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.GetChat")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
 		c.Abort()
 		return
 	}
 
-	chat, err := ctrl.db.GetChat(uint(id))
+	chat, err := ctrl.db.GetChat(c.Request.Context(), uint(id))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no chat with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Info(
+		ctrl.log(c).Info(
 			fmt.Sprintf("ctrl.db.GetChat(uint(%d)): %s", id, err),
 			"method",
 			"ctrl.GetChat",
@@ -523,14 +926,14 @@ func (ctrl *Controller) GetChat(c *gin.Context) {
 }
 
 func (ctrl *Controller) GetAllChats(c *gin.Context) {
-	chats, err := ctrl.db.GetAllChats()
+	chats, err := ctrl.db.GetAllChats(c.Request.Context())
 	if err != nil {
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.GetAllChats(): %s", err),
 			"method",
 			"ctrl.GetAllChats",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
@@ -542,30 +945,34 @@ func (ctrl *Controller) UpdateChat(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.UpdateChat")
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.UpdateChat")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
 		c.Abort()
 		return
 	}
 
+	if _, ok := ctrl.requireChatOwner(c, uint(id), "ctrl.UpdateChat"); !ok {
+		return
+	}
+
 	var chat database.Chat
 	if err := c.BindJSON(&chat); err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid chat json: %s", err), "method", "ctrl.UpdateChat")
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat json: %s", err), "method", "ctrl.UpdateChat")
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
 		c.Abort()
 		return
 	}
 
 	chat.ID = uint(id)
-	updatedChat, err := ctrl.db.UpdateChat(&chat)
+	updatedChat, err := ctrl.db.UpdateChat(c.Request.Context(), &chat)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no chat with this id"})
 		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+			serverError(c, err)
 		}
 
-		ctrl.logger.Error(
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.UpdateChat(%#v): %s", &chat, err),
 			"method",
 			"ctrl.UpdateChat",
@@ -581,22 +988,82 @@ func (ctrl *Controller) DeleteChat(c *gin.Context) {
 	strID := c.Param("id")
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		ctrl.logger.Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.DeleteChat")
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.DeleteChat")
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
 		c.Abort()
 		return
 	}
 
-	if err := ctrl.db.DeleteChat(uint(id)); err != nil {
-		ctrl.logger.Error(
+	if _, ok := ctrl.requireChatOwner(c, uint(id), "ctrl.DeleteChat"); !ok {
+		return
+	}
+
+	if err := ctrl.db.DeleteChat(c.Request.Context(), uint(id)); err != nil {
+		ctrl.log(c).Error(
 			fmt.Sprintf("ctrl.db.DeleteChat(%d): %s", id, err),
 			"method",
 			"ctrl.DeleteChat",
 		)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+		serverError(c, err)
 		c.Abort()
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "successfully deleted"})
 }
+
+func (ctrl *Controller) AddChatMember(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.AddChatMember")
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		c.Abort()
+		return
+	}
+
+	var member struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := c.BindJSON(&member); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid member json: %s", err), "method", "ctrl.AddChatMember")
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	chat, ok := ctrl.requireChatOwner(c, uint(id), "ctrl.AddChatMember")
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.db.GetUserByID(c.Request.Context(), member.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.db.GetUserByID(%d): %s", member.UserID, err),
+			"method",
+			"ctrl.AddChatMember",
+		)
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.db.AddUserToChat(c.Request.Context(), user, chat); err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.AddUserToChat(%d, %d): %s", user.ID, chat.ID, err),
+			"method",
+			"ctrl.AddChatMember",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully added"})
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is the status of requests the client canceled.
+// Nobody reads the response, it is only seen in the logs and metrics.
+const StatusClientClosedRequest = 499
+
+// serverError answers for an error that is not the fault of the request.
+// Canceled and timed out requests get their own statuses, so they are not
+// mistaken for failures of the server.
+func serverError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.IndentedJSON(StatusClientClosedRequest, gin.H{"error": "request is canceled"})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.IndentedJSON(http.StatusServiceUnavailable, gin.H{"error": "request timed out"})
+	default:
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "server is unavailable now"})
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/LLIEPJIOK/forum/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz answers as long as the process serves requests.
+func (ctrl *Controller) Healthz(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readyz answers with 503 while the database is unreachable, migrations
+// are pending or the server is shutting down.
+func (ctrl *Controller) Readyz(c *gin.Context) {
+	if err := ctrl.health.Ready(c.Request.Context()); err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("ctrl.health.Ready(): %s", err), "method", "ctrl.Readyz")
+		c.IndentedJSON(
+			http.StatusServiceUnavailable,
+			gin.H{"status": health.StatusDown, "error": err.Error()},
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Health reports every dependency with its latency. When a health token is
+// configured, it must be sent as the bearer token.
+func (ctrl *Controller) Health(c *gin.Context) {
+	token, _ := bearerToken(c)
+	if !ctrl.health.Authorized(token) {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid health token"})
+		c.Abort()
+		return
+	}
+
+	report := ctrl.health.Report(c.Request.Context())
+	if report.Status != health.StatusUp {
+		c.IndentedJSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, report)
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LLIEPJIOK/forum/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) PostIncomingWebhook(c *gin.Context) {
+	var payload webhook.Payload
+	if err := c.BindJSON(&payload); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid incoming webhook json: %s", err),
+			"method",
+			"ctrl.PostIncomingWebhook",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	message, err := ctrl.incoming.Post(c.Request.Context(), c.Param("token"), &payload)
+	if err != nil {
+		if errors.Is(err, webhook.ErrHookNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if errors.Is(err, webhook.ErrInvalidHookPayload) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.incoming.Post(): %s", err),
+			"method",
+			"ctrl.PostIncomingWebhook",
+		)
+		c.Abort()
+		return
+	}
+
+	ctrl.metrics.MessageSent()
+
+	c.IndentedJSON(http.StatusOK, message)
+}
+
+func (ctrl *Controller) AddIncomingWebhook(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid chat id: %s", err), "method", "ctrl.AddIncomingWebhook")
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		c.Abort()
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid incoming webhook json: %s", err),
+			"method",
+			"ctrl.AddIncomingWebhook",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	hook, token, err := ctrl.incoming.Create(c.Request.Context(), uint(id), request.Name)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidIncoming) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no chat with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.incoming.Create(%d): %s", id, err),
+			"method",
+			"ctrl.AddIncomingWebhook",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"webhook": hook,
+		"token":   token,
+		"path":    "/hooks/" + token,
+	})
+}
+
+func (ctrl *Controller) GetChatIncomingWebhooks(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid chat id: %s", err),
+			"method",
+			"ctrl.GetChatIncomingWebhooks",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		c.Abort()
+		return
+	}
+
+	hooks, err := ctrl.db.GetChatIncomingWebhooks(c.Request.Context(), uint(id))
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.GetChatIncomingWebhooks(%d): %s", id, err),
+			"method",
+			"ctrl.GetChatIncomingWebhooks",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, hooks)
+}
+
+func (ctrl *Controller) RotateIncomingWebhook(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid incoming webhook id: %s", err),
+			"method",
+			"ctrl.RotateIncomingWebhook",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid incoming webhook id"})
+		c.Abort()
+		return
+	}
+
+	hook, token, err := ctrl.incoming.Rotate(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no incoming webhook with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.incoming.Rotate(%d): %s", id, err),
+			"method",
+			"ctrl.RotateIncomingWebhook",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"webhook": hook,
+		"token":   token,
+		"path":    "/hooks/" + token,
+	})
+}
+
+func (ctrl *Controller) UpdateIncomingWebhook(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid incoming webhook id: %s", err),
+			"method",
+			"ctrl.UpdateIncomingWebhook",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid incoming webhook id"})
+		c.Abort()
+		return
+	}
+
+	var request struct {
+		Disabled bool `json:"disabled"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid incoming webhook json: %s", err),
+			"method",
+			"ctrl.UpdateIncomingWebhook",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	hook, err := ctrl.db.SetIncomingWebhookDisabled(c.Request.Context(), uint(id), request.Disabled)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no incoming webhook with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.SetIncomingWebhookDisabled(%d): %s", id, err),
+			"method",
+			"ctrl.UpdateIncomingWebhook",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, hook)
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	loggerKey       = "logger"
+)
+
+// validRequestID limits the request ids taken from clients, so they cannot
+// inject arbitrary text in the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// LogRequest takes the request id from the client or generates it, returns it
+// in the response, and writes one access log line when the request is handled.
+// Handlers log through the request logger, so their lines have the request id.
+func (ctrl *Controller) LogRequest(c *gin.Context) {
+	start := time.Now()
+
+	requestID := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	c.Header(requestIDHeader, requestID)
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	logger := ctrl.logger.With(
+		"request_id", requestID,
+		"http_method", c.Request.Method,
+		"route", route,
+	)
+	c.Set(loggerKey, logger)
+
+	c.Next()
+
+	level := slog.LevelInfo
+	if c.Writer.Status() >= 500 {
+		level = slog.LevelError
+	}
+
+	attrs := []any{
+		"status", c.Writer.Status(),
+		"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		"bytes", max(c.Writer.Size(), 0),
+		"client_ip", c.ClientIP(),
+	}
+	attrs = append(attrs, tracing.LogAttrs(c.Request.Context())...)
+	if user, ok := c.Get(userKey); ok {
+		attrs = append(attrs, "user_id", user.(*database.User).ID)
+	}
+
+	logger.Log(c.Request.Context(), level, "request", attrs...)
+}
+
+// log returns the logger of the request with its trace and user.
+func (ctrl *Controller) log(c *gin.Context) *slog.Logger {
+	logger := ctrl.logger
+	if value, ok := c.Get(loggerKey); ok {
+		logger = value.(*slog.Logger)
+	}
+
+	logger = logger.With(tracing.LogAttrs(c.Request.Context())...)
+	if user, ok := c.Get(userKey); ok {
+		logger = logger.With("user_id", user.(*database.User).ID)
+	}
+
+	return logger
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
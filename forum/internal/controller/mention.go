@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (ctrl *Controller) GetUserMentions(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(fmt.Sprintf("invalid user id: %s", err), "method", "ctrl.GetUserMentions")
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		c.Abort()
+		return
+	}
+
+	mentions, err := ctrl.db.GetUserMentions(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.db.GetUserMentions(uint(%d)): %s", id, err),
+			"method",
+			"ctrl.GetUserMentions",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, mentions)
+}
+
+func (ctrl *Controller) GetTagPosts(c *gin.Context) {
+	name := c.Param("name")
+
+	posts, err := ctrl.db.GetPostsByTag(c.Request.Context(), name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no tag with this name"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.db.GetPostsByTag(%q): %s", name, err),
+			"method",
+			"ctrl.GetTagPosts",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, posts)
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests to unknown routes, so scanners cannot create
+// a series for every path they try.
+const unmatchedRoute = "unmatched"
+
+// ObserveRequest records the request under its route template and status.
+func (ctrl *Controller) ObserveRequest(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	ctrl.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
+
+// Metrics serves the metrics in the Prometheus text format.
+func (ctrl *Controller) Metrics(c *gin.Context) {
+	ctrl.metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userKey   = "user"
+	apiKeyKey = "api_key"
+)
+
+// RequireUser authenticates the request by its bearer session token or API key
+// and stores the user in the context.
+func (ctrl *Controller) RequireUser(c *gin.Context) {
+	if _, ok := c.Get(userKey); ok {
+		return
+	}
+
+	token, ok := bearerToken(c)
+	if !ok {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+		c.Abort()
+		return
+	}
+
+	user, apiKey, err := ctrl.authenticate(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			c.IndentedJSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "session or api key is invalid or expired"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticate(): %s", err),
+			"method",
+			"ctrl.RequireUser",
+		)
+		c.Abort()
+		return
+	}
+
+	c.Set(userKey, user)
+	if apiKey != nil {
+		c.Set(apiKeyKey, apiKey)
+	}
+}
+
+// RequireSession rejects requests made with API keys. It must run after RequireUser.
+func (ctrl *Controller) RequireSession(c *gin.Context) {
+	if _, ok := c.Get(apiKeyKey); ok {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "api keys cannot be used here"})
+		c.Abort()
+		return
+	}
+}
+
+// RequireScope authenticates requests with a bearer token and rejects the ones
+// made with API keys that lack the scope. Session tokens have every scope.
+// Anonymous requests pass, routes that need a user run RequireUser before it.
+func (ctrl *Controller) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := bearerToken(c); !ok {
+			return
+		}
+
+		ctrl.RequireUser(c)
+		if c.IsAborted() {
+			return
+		}
+
+		value, ok := c.Get(apiKeyKey)
+		if !ok {
+			return
+		}
+
+		if apiKey := value.(*database.APIKey); !slices.Contains(apiKey.Scopes, scope) {
+			c.IndentedJSON(
+				http.StatusForbidden,
+				gin.H{"error": fmt.Sprintf("api key has no %q scope", scope)},
+			)
+			c.Abort()
+			return
+		}
+	}
+}
+
+// authenticate returns the user of the session token or of the API key with the key.
+func (ctrl *Controller) authenticate(
+	ctx context.Context,
+	token string,
+) (*database.User, *database.APIKey, error) {
+	if strings.HasPrefix(token, auth.APIKeyPrefix) {
+		user, apiKey, err := ctrl.authenticator.AuthenticateAPIKey(ctx, token)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ctrl.authenticator.AuthenticateAPIKey(): %w", err)
+		}
+
+		return user, apiKey, nil
+	}
+
+	user, err := ctrl.authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ctrl.authenticator.Authenticate(): %w", err)
+	}
+
+	return user, nil, nil
+}
+
+// RequireTwoFactor rejects users whose role must use two-factor authentication
+// until they enable it. It must run after RequireUser.
+func (ctrl *Controller) RequireTwoFactor(c *gin.Context) {
+	ctrl.requireTwoFactor(c)
+}
+
+func (ctrl *Controller) requireTwoFactor(c *gin.Context) bool {
+	user := currentUser(c)
+
+	missing, err := ctrl.authenticator.MissingTwoFactor(c.Request.Context(), user)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.authenticator.MissingTwoFactor(%d): %s", user.ID, err),
+			"method",
+			"ctrl.requireTwoFactor",
+		)
+		serverError(c, err)
+		c.Abort()
+		return false
+	}
+
+	if missing {
+		c.IndentedJSON(
+			http.StatusForbidden,
+			gin.H{"error": "two-factor authentication must be enabled for your role"},
+		)
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// RequireRole rejects users without one of the roles. It must run after RequireUser.
+func (ctrl *Controller) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, currentUser(c).Role) {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"error": "not enough permissions"})
+			c.Abort()
+			return
+		}
+	}
+}
+
+// requireOwner rejects the request unless the current user is the owner
+// or has one of the roles. Users acting by their role must have two-factor
+// authentication enabled if the role requires it. It must run after RequireUser.
+func (ctrl *Controller) requireOwner(c *gin.Context, ownerID uint, roles ...string) bool {
+	user := currentUser(c)
+	if user.ID == ownerID {
+		return true
+	}
+
+	if !slices.Contains(roles, user.Role) {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "not enough permissions"})
+		c.Abort()
+		return false
+	}
+
+	return ctrl.requireTwoFactor(c)
+}
+
+// currentUser returns the user stored by RequireUser.
+func currentUser(c *gin.Context) *database.User {
+	return c.MustGet(userKey).(*database.User)
+}
+
+// RateLimit limits requests of the authenticated user or, for anonymous requests,
+// of the client IP to the matched route. Bearer tokens are looked up within the
+// auth limit of the client IP. Requests pass if the limiter is unavailable.
+// The authenticated user is kept in the context, so RequireUser does not look it up again.
+func (ctrl *Controller) RateLimit(c *gin.Context) {
+	client := "ip:" + c.ClientIP()
+	if token, ok := bearerToken(c); ok {
+		result, err := ctrl.limiter.AllowAuth(c.Request.Context(), client)
+		if err != nil {
+			ctrl.log(c).Error(
+				fmt.Sprintf("ctrl.limiter.AllowAuth(%q): %s", client, err),
+				"method",
+				"ctrl.RateLimit",
+			)
+		} else if !withinLimit(c, result) {
+			return
+		}
+
+		if user, apiKey, err := ctrl.authenticate(c.Request.Context(), token); err == nil {
+			client = fmt.Sprintf("user:%d", user.ID)
+
+			c.Set(userKey, user)
+			if apiKey != nil {
+				c.Set(apiKeyKey, apiKey)
+			}
+		}
+	}
+
+	route := fmt.Sprintf("%s %s", c.Request.Method, c.FullPath())
+	result, err := ctrl.limiter.Allow(c.Request.Context(), route, client)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.limiter.Allow(%q, %q): %s", route, client, err),
+			"method",
+			"ctrl.RateLimit",
+		)
+		return
+	}
+
+	withinLimit(c, result)
+}
+
+// withinLimit sets the rate limit headers of the result and rejects the request
+// if the limit is exceeded. It reports whether the request may go on.
+func withinLimit(c *gin.Context, result *ratelimit.Result) bool {
+	if result.Limit == 0 {
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
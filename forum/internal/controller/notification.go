@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetNotifications returns the notifications of the current user.
+func (ctrl *Controller) GetNotifications(c *gin.Context) {
+	userID := currentUser(c).ID
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, err := ctrl.db.GetNotifications(c.Request.Context(), userID, unreadOnly)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.db.GetNotifications(%d, %t): %s", userID, unreadOnly, err),
+			"method",
+			"ctrl.GetNotifications",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, notifications)
+}
+
+func (ctrl *Controller) MarkNotificationRead(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid notification id: %s", err),
+			"method",
+			"ctrl.MarkNotificationRead",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+		c.Abort()
+		return
+	}
+
+	userID := currentUser(c).ID
+	notification, err := ctrl.db.MarkNotificationRead(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no notification with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.MarkNotificationRead(%d, %d): %s", userID, id, err),
+			"method",
+			"ctrl.MarkNotificationRead",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead marks all notifications of the current user as read.
+func (ctrl *Controller) MarkAllNotificationsRead(c *gin.Context) {
+	userID := currentUser(c).ID
+	if err := ctrl.db.MarkAllNotificationsRead(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.MarkAllNotificationsRead(%d): %s", userID, err),
+			"method",
+			"ctrl.MarkAllNotificationsRead",
+		)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully marked as read"})
+}
+
+// StreamNotifications pushes new notifications of the current user as server-sent
+// events until the client disconnects.
+func (ctrl *Controller) StreamNotifications(c *gin.Context) {
+	// The stream stays open until the client leaves or the server shuts down,
+	// so the write timeout of the server does not apply to it.
+	err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("cannot clear write deadline: %s", err),
+			"method",
+			"ctrl.StreamNotifications",
+		)
+	}
+
+	notifications, unsubscribe := ctrl.notifications.Subscribe(currentUser(c).ID)
+	defer unsubscribe()
+
+	ctrl.metrics.StreamOpened()
+	defer ctrl.metrics.StreamClosed()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case notification, ok := <-notifications:
+			if !ok {
+				return false
+			}
+
+			c.SSEvent("notification", notification)
+			return true
+		}
+	})
+}
+
+func (ctrl *Controller) GetNotificationPreference(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid user id: %s", err),
+			"method",
+			"ctrl.GetNotificationPreference",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		c.Abort()
+		return
+	}
+
+	if !ctrl.requireOwner(c, uint(id), database.RoleAdmin) {
+		return
+	}
+
+	preference, err := ctrl.db.GetNotificationPreference(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.db.GetNotificationPreference(uint(%d)): %s", id, err),
+			"method",
+			"ctrl.GetNotificationPreference",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, preference)
+}
+
+func (ctrl *Controller) UpdateNotificationPreference(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid user id: %s", err),
+			"method",
+			"ctrl.UpdateNotificationPreference",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		c.Abort()
+		return
+	}
+
+	if !ctrl.requireOwner(c, uint(id), database.RoleAdmin) {
+		return
+	}
+
+	var preference database.NotificationPreference
+	if err := c.BindJSON(&preference); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid notification preference json: %s", err),
+			"method",
+			"ctrl.UpdateNotificationPreference",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	switch preference.EmailMode {
+	case database.EmailImmediate, database.EmailDailyDigest, database.EmailOff:
+	default:
+		c.IndentedJSON(
+			http.StatusBadRequest,
+			gin.H{"error": "email_mode must be one of immediate, daily or off"},
+		)
+		c.Abort()
+		return
+	}
+
+	preference.UserID = uint(id)
+	updatedPreference, err := ctrl.db.SetNotificationPreference(c.Request.Context(), &preference)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no user with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.SetNotificationPreference(%#v): %s", &preference, err),
+			"method",
+			"ctrl.UpdateNotificationPreference",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, updatedPreference)
+}
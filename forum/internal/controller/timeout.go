@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryTimeouts bound how long a request may wait for the database.
+// Routes are keyed by the method and the route template, like "GET /post/:id",
+// and override the default. Zero disables the timeout of a route.
+type QueryTimeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// Timeout cancels the context of the request when its timeout passes, so its
+// queries are canceled too. Queries are also canceled when the client leaves.
+func (ctrl *Controller) Timeout(c *gin.Context) {
+	timeout, ok := ctrl.timeouts.Routes[fmt.Sprintf("%s %s", c.Request.Method, c.FullPath())]
+	if !ok {
+		timeout = ctrl.timeouts.Default
+	}
+
+	if timeout <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/LLIEPJIOK/forum/internal/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// Trace runs the rest of the request in a span named after its handler.
+func (ctrl *Controller) Trace(c *gin.Context) {
+	if c.FullPath() == "" {
+		c.Next()
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), handlerName(c.HandlerName()))
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// handlerName turns the name of a method value, like
+// github.com/LLIEPJIOK/forum/internal/controller.(*Controller).AddUser-fm,
+// into controller.AddUser.
+func handlerName(name string) string {
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+
+	return "controller." + name
+}
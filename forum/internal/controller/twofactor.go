@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+func (ctrl *Controller) EnrollTwoFactor(c *gin.Context) {
+	user := currentUser(c)
+
+	enrollment, err := ctrl.authenticator.EnrollTwoFactor(c.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, auth.ErrTwoFactorEnabled) {
+			c.IndentedJSON(
+				http.StatusConflict,
+				gin.H{"error": "two-factor authentication is already enabled"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.authenticator.EnrollTwoFactor(%d): %s", user.ID, err),
+			"method",
+			"ctrl.EnrollTwoFactor",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, enrollment)
+}
+
+func (ctrl *Controller) ConfirmTwoFactor(c *gin.Context) {
+	user := currentUser(c)
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid two-factor confirmation json: %s", err),
+			"method",
+			"ctrl.ConfirmTwoFactor",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	recoveryCodes, err := ctrl.authenticator.ConfirmTwoFactor(
+		c.Request.Context(),
+		user,
+		request.Code,
+	)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCode) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid two-factor code"})
+		} else if errors.Is(err, auth.ErrTwoFactorNotEnrolled) {
+			c.IndentedJSON(
+				http.StatusBadRequest,
+				gin.H{"error": "two-factor authentication is not enrolled"},
+			)
+		} else if errors.Is(err, auth.ErrTwoFactorEnabled) {
+			c.IndentedJSON(
+				http.StatusConflict,
+				gin.H{"error": "two-factor authentication is already enabled"},
+			)
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Info(
+			fmt.Sprintf("ctrl.authenticator.ConfirmTwoFactor(%d): %s", user.ID, err),
+			"method",
+			"ctrl.ConfirmTwoFactor",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+func (ctrl *Controller) GetAllTwoFactorPolicies(c *gin.Context) {
+	policies, err := ctrl.db.GetAllTwoFactorPolicies(c.Request.Context())
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.GetAllTwoFactorPolicies(): %s", err),
+			"method",
+			"ctrl.GetAllTwoFactorPolicies",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, policies)
+}
+
+func (ctrl *Controller) SetTwoFactorPolicy(c *gin.Context) {
+	var policy database.TwoFactorPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid two-factor policy json: %s", err),
+			"method",
+			"ctrl.SetTwoFactorPolicy",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	policy.Role = c.Param("role")
+	switch policy.Role {
+	case database.RoleUser, database.RoleModerator, database.RoleAdmin:
+	default:
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no such role"})
+		c.Abort()
+		return
+	}
+
+	updatedPolicy, err := ctrl.db.SetTwoFactorPolicy(c.Request.Context(), &policy)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.SetTwoFactorPolicy(%#v): %s", &policy, err),
+			"method",
+			"ctrl.SetTwoFactorPolicy",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, updatedPolicy)
+}
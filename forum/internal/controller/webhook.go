@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LLIEPJIOK/forum/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const webhookDeliveriesLimit = 100
+
+func (ctrl *Controller) AddWebhookSubscription(c *gin.Context) {
+	var request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid webhook subscription json: %s", err),
+			"method",
+			"ctrl.AddWebhookSubscription",
+		)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "json is invalid"})
+		c.Abort()
+		return
+	}
+
+	subscription, secret, err := ctrl.webhooks.Subscribe(request.URL, request.Events)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidSubscription) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.webhooks.Subscribe(%q): %s", request.URL, err),
+			"method",
+			"ctrl.AddWebhookSubscription",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{"subscription": subscription, "secret": secret})
+}
+
+func (ctrl *Controller) GetAllWebhookSubscriptions(c *gin.Context) {
+	subscriptions, err := ctrl.db.GetAllWebhookSubscriptions(c.Request.Context())
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.GetAllWebhookSubscriptions(): %s", err),
+			"method",
+			"ctrl.GetAllWebhookSubscriptions",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, subscriptions)
+}
+
+func (ctrl *Controller) DeleteWebhookSubscription(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid webhook subscription id: %s", err),
+			"method",
+			"ctrl.DeleteWebhookSubscription",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid webhook subscription id"})
+		c.Abort()
+		return
+	}
+
+	if err := ctrl.db.DeleteWebhookSubscription(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no webhook subscription with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.DeleteWebhookSubscription(%d): %s", id, err),
+			"method",
+			"ctrl.DeleteWebhookSubscription",
+		)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully deleted"})
+}
+
+func (ctrl *Controller) GetWebhookDeliveries(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid webhook subscription id: %s", err),
+			"method",
+			"ctrl.GetWebhookDeliveries",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid webhook subscription id"})
+		c.Abort()
+		return
+	}
+
+	deliveries, err := ctrl.db.GetWebhookDeliveries(
+		c.Request.Context(),
+		uint(id),
+		webhookDeliveriesLimit,
+	)
+	if err != nil {
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.GetWebhookDeliveries(%d): %s", id, err),
+			"method",
+			"ctrl.GetWebhookDeliveries",
+		)
+		serverError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, deliveries)
+}
+
+func (ctrl *Controller) RedeliverWebhook(c *gin.Context) {
+	strID := c.Param("id")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		ctrl.log(c).Info(
+			fmt.Sprintf("invalid webhook delivery id: %s", err),
+			"method",
+			"ctrl.RedeliverWebhook",
+		)
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid webhook delivery id"})
+		c.Abort()
+		return
+	}
+
+	delivery, err := ctrl.db.RedeliverWebhook(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no webhook delivery with this id"})
+		} else {
+			serverError(c, err)
+		}
+
+		ctrl.log(c).Error(
+			fmt.Sprintf("ctrl.db.RedeliverWebhook(%d): %s", id, err),
+			"method",
+			"ctrl.RedeliverWebhook",
+		)
+		c.Abort()
+		return
+	}
+
+	c.IndentedJSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MarkUserVerified verifies the user without a verification token.
+func (db *Database) MarkUserVerified(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&User{}).
+		Where("id = ? AND verified_at IS NULL", id).
+		Update("verified_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("cannot verify user with id = %d: %w", id, result.Error)
+	}
+
+	return nil
+}
+
+func (db *Database) SetUserRole(ctx context.Context, id uint, role string) (*User, error) {
+	db = db.withContext(ctx)
+
+	if !slices.Contains(Roles, role) {
+		return nil, fmt.Errorf("cannot set role %q of user with id = %d: %w", role, id, ErrInvalidRole)
+	}
+
+	result := db.gormDB.Model(&User{}).
+		Where("id = ? AND removed_at IS NULL", id).
+		Update("role", role)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot set role of user with id = %d: %w", id, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("cannot set role of user with id = %d: %w", id, gorm.ErrRecordNotFound)
+	}
+
+	user, err := db.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetUserByID(%d): %w", id, err)
+	}
+
+	return user, nil
+}
+
+// DisableUser stops the user from logging in and revokes their sessions and API keys.
+// Unlike DeleteUser it keeps the nickname and email of the user.
+func (db *Database) DisableUser(ctx context.Context, id uint) (*User, error) {
+	db = db.withContext(ctx)
+
+	var user *User
+
+	err := db.Transaction(func(tx *Database) error {
+		now := time.Now()
+		result := tx.gormDB.Model(&User{}).
+			Where("id = ? AND removed_at IS NULL AND disabled_at IS NULL", id).
+			Update("disabled_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("cannot disable user with id = %d: %w", id, result.Error)
+		}
+
+		if err := tx.RevokeUserSessions(id); err != nil {
+			return fmt.Errorf("tx.RevokeUserSessions(%d): %w", id, err)
+		}
+
+		result = tx.gormDB.Model(&APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("cannot revoke api keys of user with id = %d: %w", id, result.Error)
+		}
+
+		var err error
+		user, err = tx.GetUserByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("tx.GetUserByID(%d): %w", id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
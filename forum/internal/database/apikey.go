@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyTouchInterval limits how often the last usage of a key is written.
+const apiKeyTouchInterval = time.Minute
+
+func (db *Database) AddAPIKey(ctx context.Context, key *APIKey) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Create(key)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot add api key of user with id = %d to db: %w",
+			key.UserID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// GetActiveAPIKey returns the key with the hash if it is not expired or revoked.
+func (db *Database) GetActiveAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	db = db.withContext(ctx)
+
+	key := &APIKey{}
+	result := db.gormDB.
+		Where(
+			"key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+			keyHash,
+			time.Now(),
+		).
+		First(key)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get active api key: %w", result.Error)
+	}
+
+	return key, nil
+}
+
+func (db *Database) GetUserAPIKeys(ctx context.Context, userID uint) ([]APIKey, error) {
+	db = db.withContext(ctx)
+
+	var keys []APIKey
+	result := db.gormDB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get api keys of user with id = %d: %w", userID, result.Error)
+	}
+
+	return keys, nil
+}
+
+// TouchAPIKey records the usage of the key unless it was recorded recently.
+func (db *Database) TouchAPIKey(ctx context.Context, id uint, at time.Time) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&APIKey{}).
+		Where(
+			"id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+			id,
+			at.Add(-apiKeyTouchInterval),
+		).
+		Update("last_used_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("cannot touch api key with id = %d: %w", id, result.Error)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the key of the user. Keys of other users are not found.
+func (db *Database) RevokeAPIKey(ctx context.Context, userID, id uint) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("cannot revoke api key with id = %d: %w", id, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("cannot revoke api key with id = %d: %w", id, gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
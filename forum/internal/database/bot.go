@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AddBot creates the bot user and the bot. Bots are verified from the start,
+// so they can send messages right away. Nicknames of bots are unique.
+func (db *Database) AddBot(bot *Bot) error {
+	now := time.Now()
+	bot.User.Email = nil
+	bot.User.HashPassword = ""
+	bot.User.VerifiedAt = &now
+	bot.User.Role = RoleUser
+	bot.User.IsBot = true
+
+	err := db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Create(&bot.User)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot add bot user %q to db: %w",
+				bot.User.Nickname,
+				translateError(result.Error),
+			)
+		}
+
+		bot.UserID = bot.User.ID
+
+		result = tx.gormDB.Omit("User").Create(bot)
+		if result.Error != nil {
+			return fmt.Errorf("cannot add bot %q to db: %w", bot.User.Nickname, result.Error)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot add bot with nickname = %q: %w", bot.User.Nickname, err)
+	}
+
+	return nil
+}
+
+// GetBotByNickname returns the bot with the nickname that is not removed.
+func (db *Database) GetBotByNickname(nickname string) (*Bot, error) {
+	bot := &Bot{}
+	result := db.gormDB.
+		Joins("User").
+		Where(`"User".nickname = ? AND "User".removed_at IS NULL`, nickname).
+		First(bot)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get bot by nickname = %q: %w", nickname, result.Error)
+	}
+
+	return bot, nil
+}
+
+// EnsureBot returns the bot with the nickname and creates it if it does not exist.
+func (db *Database) EnsureBot(nickname string) (*Bot, error) {
+	bot, err := db.GetBotByNickname(nickname)
+	if err == nil {
+		return bot, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("db.GetBotByNickname(%q): %w", nickname, err)
+	}
+
+	bot = &Bot{User: User{Nickname: nickname}}
+	err = db.AddBot(bot)
+	if errors.Is(err, ErrUniqueConstraint) {
+		// The bot was created concurrently.
+		bot, err = db.GetBotByNickname(nickname)
+		if err != nil {
+			return nil, fmt.Errorf("db.GetBotByNickname(%q): %w", nickname, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("db.AddBot(%q): %w", nickname, err)
+	}
+
+	return bot, nil
+}
+
+func (db *Database) GetAllBots(ctx context.Context) ([]Bot, error) {
+	db = db.withContext(ctx)
+
+	var bots []Bot
+	result := db.gormDB.
+		Joins("User").
+		Where(`"User".removed_at IS NULL`).
+		Order("bots.user_id").
+		Find(&bots)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get all bots: %w", result.Error)
+	}
+
+	return bots, nil
+}
+
+// GetChatBots returns the bots that are members of the chat.
+func (db *Database) GetChatBots(chatID uint) ([]Bot, error) {
+	var bots []Bot
+	result := db.gormDB.
+		Joins("User").
+		Joins("JOIN user_x_chat ON user_x_chat.user_id = bots.user_id").
+		Where(`user_x_chat.chat_id = ? AND "User".removed_at IS NULL`, chatID).
+		Find(&bots)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get bots of chat with id = %d: %w", chatID, result.Error)
+	}
+
+	return bots, nil
+}
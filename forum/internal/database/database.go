@@ -1,57 +1,70 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/LLIEPJIOK/forum/internal/markdown"
 	"gorm.io/gorm"
 )
 
 var (
 	ErrUniqueConstraint     = errors.New("duplicate primary key value violates uniqueness constraint")
 	ErrForeignKeyConstraint = errors.New("missing key in external table violates foreign key constraint")
+	ErrUnverified           = errors.New("user email is not verified")
+	ErrTokenInvalid         = errors.New("token is expired or already used")
+	ErrInvalidRole          = errors.New("role is unknown")
 )
 
 type Database struct {
-	gormDB *gorm.DB
+	gormDB    *gorm.DB
+	publisher NotificationPublisher
+
+	// pending holds the notifications to publish after the commit
+	// when the database runs in a transaction.
+	pending *[]*Notification
+
+	// silent skips notifications, so reindexed or imported content
+	// does not notify users again.
+	silent bool
 }
 
-func New(gormDB *gorm.DB) *Database {
+func New(gormDB *gorm.DB, publisher NotificationPublisher) *Database {
 	return &Database{
-		gormDB: gormDB,
+		gormDB:    gormDB,
+		publisher: publisher,
 	}
 }
 
-func (db *Database) Migrate() error {
-	err := db.gormDB.AutoMigrate(User{}, Post{}, Message{}, Chat{})
-	if err != nil {
-		return fmt.Errorf("cannot create tables: %w", err)
-	}
+// AddUser creates an unverified user. The user is verified with VerifyUserEmail.
+func (db *Database) AddUser(ctx context.Context, user *User) error {
+	db = db.withContext(ctx)
 
-	return nil
-}
+	user.VerifiedAt = nil
+	user.Role = RoleUser
+	user.DisabledAt = nil
+	user.IsBot = false
 
-func (db *Database) AddUser(user *User) error {
-	_, err := db.GetUserByEmail(*user.Email)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			result := db.gormDB.Create(user)
-			if result.Error != nil {
-				return fmt.Errorf("cannot add user %#v to db: %w", user, result.Error)
-			}
-
-			return nil
-		} else {
-			return fmt.Errorf("cannot check email = %#v existence: %w", user.Email, err)
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Create(user)
+		if result.Error != nil {
+			return fmt.Errorf("cannot add user %q to db: %w", user.Nickname, translateError(result.Error))
 		}
-	}
 
-	return fmt.Errorf("cannot add user %#v to db: %w", user, ErrUniqueConstraint)
+		return tx.addOutboxEvent(AggregateUser, user.ID, EventUserCreated, &userEventData{
+			ID:           user.ID,
+			Nickname:     user.Nickname,
+			RegisteredAt: user.RegisteredAt,
+		})
+	})
 }
 
-func (db *Database) GetUserByEmail(email string) (*User, error) {
+func (db *Database) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	db = db.withContext(ctx)
+
 	user := &User{}
 	result := db.gormDB.Where("email = ?", email).First(user)
 	if result.Error != nil {
@@ -61,7 +74,9 @@ func (db *Database) GetUserByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-func (db *Database) GetUserByID(id uint) (*User, error) {
+func (db *Database) GetUserByID(ctx context.Context, id uint) (*User, error) {
+	db = db.withContext(ctx)
+
 	user := &User{}
 	result := db.gormDB.Where("id = ?", id).First(user)
 	if result.Error != nil {
@@ -74,7 +89,9 @@ func (db *Database) GetUserByID(id uint) (*User, error) {
 	return user, nil
 }
 
-func (db *Database) GetAllUsers() ([]*User, error) {
+func (db *Database) GetAllUsers(ctx context.Context) ([]*User, error) {
+	db = db.withContext(ctx)
+
 	var users []*User
 	result := db.gormDB.Where("removed_at IS NULL").Find(&users)
 	if result.Error != nil {
@@ -84,193 +101,402 @@ func (db *Database) GetAllUsers() ([]*User, error) {
 	return users, nil
 }
 
-func (db *Database) UpdateUser(user *User) (*User, error) {
-	if *user.Email != "" {
-		if err := db.gormDB.Where("email = ?", user.Email).First(&User{}).Error; err == nil {
-			return nil, fmt.Errorf("cannot update user %#v: %w", user, ErrUniqueConstraint)
+// UpdateUser updates the nickname, email and password of the user.
+// A new email has to be verified again.
+func (db *Database) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	db = db.withContext(ctx)
+
+	var updatedUser *User
+
+	err := db.Transaction(func(tx *Database) error {
+		current, err := tx.GetUserByID(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("cannot update user with id = %d: %w", user.ID, err)
 		}
-	}
 
-	_, err := db.GetUserByID(user.ID)
-	if err == nil {
-		return nil, fmt.Errorf("cannot update user %#v: %w", user, gorm.ErrRecordNotFound)
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, fmt.Errorf("cannot update user %#v: %w", user, err)
-	}
+		result := tx.gormDB.Model(&User{}).
+			Omit("verified_at", "role", "disabled_at", "is_bot").
+			Where("id = ?", user.ID).
+			Updates(user)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot update user with id = %d: %w",
+				user.ID,
+				translateError(result.Error),
+			)
+		}
 
-	result := db.gormDB.Model(&User{}).Where("id = ?", user.ID).Updates(user)
-	if result.Error != nil {
-		return nil, fmt.Errorf("cannot update user %#v: %w", user, result.Error)
-	}
+		if user.Email != nil && (current.Email == nil || *current.Email != *user.Email) {
+			if err := tx.resetVerification(user.ID); err != nil {
+				return fmt.Errorf("tx.resetVerification(%d): %w", user.ID, err)
+			}
+		}
 
-	updatedUser, err := db.GetUserByID(user.ID)
+		updatedUser, err = tx.GetUserByID(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("tx.GetUserByID(%d): %w", user.ID, err)
+		}
+
+		return tx.addOutboxEvent(AggregateUser, user.ID, EventUserUpdated, &userEventData{
+			ID:           updatedUser.ID,
+			Nickname:     updatedUser.Nickname,
+			RegisteredAt: updatedUser.RegisteredAt,
+		})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("db.GetUserByID(%d): %w", user.ID, err)
+		return nil, err
 	}
 
 	return updatedUser, nil
 }
 
-func (db *Database) DeleteUser(id uint) error {
-	result := db.gormDB.Model(&User{}).Select("nickname", "email", "removed_at").Where("id = ?", id).Updates(&User{
-		Nickname: "Deleted user",
-		Email:    nil,
-		RemovedAt: sql.NullTime{
-			Time:  time.Now(),
-			Valid: true,
-		},
-	})
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete user with id = %d: %w", id, result.Error)
-	}
+func (db *Database) DeleteUser(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Model(&User{}).
+			Select("nickname", "email", "removed_at").
+			Where("id = ?", id).
+			Updates(&User{
+				Nickname: "Deleted user",
+				Email:    nil,
+				RemovedAt: sql.NullTime{
+					Time:  time.Now(),
+					Valid: true,
+				},
+			})
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete user with id = %d: %w", id, result.Error)
+		}
 
-	result = db.gormDB.Table("user_x_chat").Where("user_id = ?", id).Delete(nil)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete user with id = %d from chats: %w", id, result.Error)
-	}
+		result = tx.gormDB.Table("user_x_chat").Where("user_id = ?", id).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete user with id = %d from chats: %w", id, result.Error)
+		}
 
-	return nil
+		return tx.addOutboxEvent(AggregateUser, id, EventUserDeleted, &deletedEventData{ID: id})
+	})
 }
 
-func (db *Database) AddPost(post *Post) error {
-	_, err := db.GetUserByID(post.AuthorID)
+func (db *Database) AddPost(ctx context.Context, post *Post) error {
+	db = db.withContext(ctx)
+
+	html, err := markdown.Render(post.Content)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("cannot add post %#v to db: %w", post, ErrForeignKeyConstraint)
-		} else {
-			return fmt.Errorf("db.GetUserByID(%d): %w", post.AuthorID, err)
+		return fmt.Errorf("cannot render post %#v: %w", post, err)
+	}
+	post.ContentHTML = html
+	post.Revision = 1
+	post.HTMLRevision = 1
+
+	return db.Transaction(func(tx *Database) error {
+		author, err := tx.GetUserByID(ctx, post.AuthorID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("cannot add post %#v to db: %w", post, ErrForeignKeyConstraint)
+			} else {
+				return fmt.Errorf("tx.GetUserByID(%d): %w", post.AuthorID, err)
+			}
 		}
-	}
 
-	result := db.gormDB.Create(post)
-	if result.Error != nil {
-		return fmt.Errorf("cannot add post %#v to db: %w", post, result.Error)
-	}
+		if author.VerifiedAt == nil {
+			return fmt.Errorf("cannot add post %#v to db: %w", post, ErrUnverified)
+		}
 
-	return nil
+		result := tx.gormDB.Create(post)
+		if result.Error != nil {
+			return fmt.Errorf("cannot add post %#v to db: %w", post, result.Error)
+		}
+
+		if err := tx.addOutboxEvent(AggregatePost, post.ID, EventPostCreated, post); err != nil {
+			return err
+		}
+
+		if err := tx.indexPost(post); err != nil {
+			return fmt.Errorf("tx.indexPost(%d): %w", post.ID, err)
+		}
+
+		return nil
+	})
 }
 
-func (db *Database) GetAllPosts() ([]*Post, error) {
+func (db *Database) GetAllPosts(ctx context.Context) ([]*Post, error) {
+	db = db.withContext(ctx)
+
 	var posts []*Post
 	result := db.gormDB.Find(&posts)
 	if result.Error != nil {
 		return nil, fmt.Errorf("cannot get all posts: %w", result.Error)
 	}
 
+	if err := db.refreshPostsHTML(posts...); err != nil {
+		return nil, fmt.Errorf("db.refreshPostsHTML(): %w", err)
+	}
+
 	return posts, nil
 }
 
-func (db *Database) GetPost(id uint) (*Post, error) {
+func (db *Database) GetPost(ctx context.Context, id uint) (*Post, error) {
+	db = db.withContext(ctx)
+
 	post := &Post{}
 	result := db.gormDB.Where("id = ?", id).First(post)
 	if result.Error != nil {
 		return nil, fmt.Errorf("cannot get post by id = %d: %w", id, result.Error)
 	}
 
+	if err := db.refreshPostsHTML(post); err != nil {
+		return nil, fmt.Errorf("db.refreshPostsHTML(%d): %w", id, err)
+	}
+
 	return post, nil
 }
 
-func (db *Database) UpdatePost(post *Post) (*Post, error) {
-	result := db.gormDB.Model(&Post{}).Select("content").Where("id = ?", post.ID).Updates(post)
-	if result.Error != nil {
-		return nil, fmt.Errorf("cannot update post %#v: %w", post, result.Error)
-	}
+func (db *Database) UpdatePost(ctx context.Context, post *Post) (*Post, error) {
+	db = db.withContext(ctx)
+
+	html, err := markdown.Render(post.Content)
+	if err != nil {
+		return nil, fmt.Errorf("cannot render post %#v: %w", post, err)
+	}
+
+	updatedPost := &Post{}
+	err = db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Model(&Post{}).Where("id = ?", post.ID).Updates(map[string]any{
+			"content":       post.Content,
+			"content_html":  html,
+			"revision":      gorm.Expr("revision + 1"),
+			"html_revision": gorm.Expr("revision + 1"),
+		})
+		if result.Error != nil {
+			return fmt.Errorf("cannot update post %#v: %w", post, result.Error)
+		}
+
+		result = tx.gormDB.Where("id = ?", post.ID).First(updatedPost)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get post by id = %d: %w", post.ID, result.Error)
+		}
+
+		err := tx.addOutboxEvent(AggregatePost, post.ID, EventPostUpdated, updatedPost)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.indexPost(updatedPost); err != nil {
+			return fmt.Errorf("tx.indexPost(%d): %w", post.ID, err)
+		}
 
-	updatedPost, err := db.GetPost(post.ID)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("db.GetPost(%d): %w", post.ID, err)
+		return nil, err
 	}
 
 	return updatedPost, nil
 }
 
-func (db *Database) DeletePost(id uint) error {
-	result := db.gormDB.Delete(&Post{}, id)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete post with id = %d: %w", id, result.Error)
-	}
+func (db *Database) DeletePost(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Where("post_id = ?", id).Delete(&Mention{})
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete mentions of post with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Table("post_x_tag").Where("post_id = ?", id).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete tags of post with id = %d: %w", id, result.Error)
+		}
 
-	return nil
+		result = tx.gormDB.Delete(&Post{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete post with id = %d: %w", id, result.Error)
+		}
+
+		return tx.addOutboxEvent(AggregatePost, id, EventPostDeleted, &deletedEventData{ID: id})
+	})
 }
 
-func (db *Database) AddMessage(message *Message) error {
-	if _, err := db.GetUserByID(message.SenderID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("cannot add message %#v to db: %w", message, ErrForeignKeyConstraint)
-		} else {
-			return fmt.Errorf("db.GetUserByID(%d): %w", message.SenderID, err)
+func (db *Database) AddMessage(ctx context.Context, message *Message) error {
+	db = db.withContext(ctx)
+
+	html, err := markdown.Render(message.Content)
+	if err != nil {
+		return fmt.Errorf("cannot render message %#v: %w", message, err)
+	}
+	message.ContentHTML = html
+	message.Revision = 1
+	message.HTMLRevision = 1
+
+	return db.Transaction(func(tx *Database) error {
+		sender, err := tx.GetUserByID(ctx, message.SenderID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("cannot add message %#v to db: %w", message, ErrForeignKeyConstraint)
+			} else {
+				return fmt.Errorf("tx.GetUserByID(%d): %w", message.SenderID, err)
+			}
 		}
-	}
 
-	if _, err := db.GetChat(message.ChatID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("cannot add message %#v to db: %w", message, ErrForeignKeyConstraint)
-		} else {
-			return fmt.Errorf("db.GetChat(%d): %w", message.ChatID, err)
+		if sender.VerifiedAt == nil {
+			return fmt.Errorf("cannot add message %#v to db: %w", message, ErrUnverified)
 		}
-	}
 
-	result := db.gormDB.Create(message)
-	if result.Error != nil {
-		return fmt.Errorf("cannot add message %#v to db: %w", message, result.Error)
-	}
+		if _, err := tx.GetChat(ctx, message.ChatID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("cannot add message %#v to db: %w", message, ErrForeignKeyConstraint)
+			} else {
+				return fmt.Errorf("tx.GetChat(%d): %w", message.ChatID, err)
+			}
+		}
+
+		result := tx.gormDB.Create(message)
+		if result.Error != nil {
+			return fmt.Errorf("cannot add message %#v to db: %w", message, result.Error)
+		}
+
+		err = tx.addOutboxEvent(AggregateMessage, message.ID, EventMessageCreated, message)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if err := tx.indexMessage(message); err != nil {
+			return fmt.Errorf("tx.indexMessage(%d): %w", message.ID, err)
+		}
+
+		return nil
+	})
 }
 
-func (db *Database) GetMessage(id uint) (*Message, error) {
+func (db *Database) GetMessage(ctx context.Context, id uint) (*Message, error) {
+	db = db.withContext(ctx)
+
 	message := &Message{}
 	result := db.gormDB.Where("id = ?", id).First(message)
 	if result.Error != nil {
 		return nil, fmt.Errorf("cannot get message by id = %d: %w", id, result.Error)
 	}
 
+	if err := db.refreshMessagesHTML(message); err != nil {
+		return nil, fmt.Errorf("db.refreshMessagesHTML(%d): %w", id, err)
+	}
+
 	return message, nil
 }
 
-func (db *Database) GetAllMessages() ([]*Message, error) {
+func (db *Database) GetAllMessages(ctx context.Context) ([]*Message, error) {
+	db = db.withContext(ctx)
+
 	var messages []*Message
 	result := db.gormDB.Find(&messages)
 	if result.Error != nil {
 		return nil, fmt.Errorf("cannot get all messages: %w", result.Error)
 	}
 
+	if err := db.refreshMessagesHTML(messages...); err != nil {
+		return nil, fmt.Errorf("db.refreshMessagesHTML(): %w", err)
+	}
+
 	return messages, nil
 }
 
-func (db *Database) UpdateMessage(message *Message) (*Message, error) {
-	result := db.gormDB.Model(&Message{}).Select("content").Where("id = ?", message.ID).Updates(message)
-	if result.Error != nil {
-		return nil, fmt.Errorf("cannot update message %#v: %w", message, result.Error)
-	}
+func (db *Database) UpdateMessage(ctx context.Context, message *Message) (*Message, error) {
+	db = db.withContext(ctx)
 
-	updatedMessage, err := db.GetMessage(message.ID)
+	html, err := markdown.Render(message.Content)
 	if err != nil {
-		return nil, fmt.Errorf("db.GetMessage(%d): %w", message.ID, err)
+		return nil, fmt.Errorf("cannot render message %#v: %w", message, err)
+	}
+
+	var updatedMessage *Message
+	err = db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Model(&Message{}).Where("id = ?", message.ID).Updates(map[string]any{
+			"content":       message.Content,
+			"content_html":  html,
+			"revision":      gorm.Expr("revision + 1"),
+			"html_revision": gorm.Expr("revision + 1"),
+		})
+		if result.Error != nil {
+			return fmt.Errorf("cannot update message %#v: %w", message, result.Error)
+		}
+
+		var err error
+		updatedMessage, err = tx.GetMessage(ctx, message.ID)
+		if err != nil {
+			return fmt.Errorf("tx.GetMessage(%d): %w", message.ID, err)
+		}
+
+		if err := tx.indexMessage(updatedMessage); err != nil {
+			return fmt.Errorf("tx.indexMessage(%d): %w", message.ID, err)
+		}
+
+		return tx.addOutboxEvent(
+			AggregateMessage,
+			message.ID,
+			EventMessageUpdated,
+			updatedMessage,
+		)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return updatedMessage, nil
 }
 
-func (db *Database) DeleteMessage(id uint) error {
-	result := db.gormDB.Delete(&Message{}, id)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete message with id = %d: %w", id, result.Error)
-	}
+func (db *Database) DeleteMessage(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Where("message_id = ?", id).Delete(&Mention{})
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete mentions of message with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Table("message_x_tag").Where("message_id = ?", id).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete tags of message with id = %d: %w", id, result.Error)
+		}
 
-	return nil
+		result = tx.gormDB.Delete(&Message{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete message with id = %d: %w", id, result.Error)
+		}
+
+		return tx.addOutboxEvent(
+			AggregateMessage,
+			id,
+			EventMessageDeleted,
+			&deletedEventData{ID: id},
+		)
+	})
 }
 
-func (db *Database) AddChat(chat *Chat) error {
-	result := db.gormDB.Create(chat)
-	if result.Error != nil {
-		return fmt.Errorf("cannot add chat %#v to db: %w", chat, result.Error)
-	}
+func (db *Database) AddChat(ctx context.Context, chat *Chat) error {
+	db = db.withContext(ctx)
 
-	return nil
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Create(chat)
+		if result.Error != nil {
+			return fmt.Errorf("cannot add chat %#v to db: %w", chat, result.Error)
+		}
+
+		if chat.OwnerID != nil {
+			result = tx.gormDB.Table("user_x_chat").
+				Create(&ChatMember{UserID: *chat.OwnerID, ChatID: chat.ID})
+			if result.Error != nil {
+				return fmt.Errorf("cannot add owner to chat with id = %d: %w", chat.ID, result.Error)
+			}
+		}
+
+		return tx.addOutboxEvent(AggregateChat, chat.ID, EventChatCreated, chat)
+	})
 }
 
-func (db *Database) GetChat(id uint) (*Chat, error) {
+func (db *Database) GetChat(ctx context.Context, id uint) (*Chat, error) {
+	db = db.withContext(ctx)
+
 	chat := &Chat{}
 	result := db.gormDB.Where("id = ?", id).First(chat)
 	if result.Error != nil {
@@ -280,7 +506,9 @@ func (db *Database) GetChat(id uint) (*Chat, error) {
 	return chat, nil
 }
 
-func (db *Database) GetAllChats() ([]*Chat, error) {
+func (db *Database) GetAllChats(ctx context.Context) ([]*Chat, error) {
+	db = db.withContext(ctx)
+
 	var chats []*Chat
 	result := db.gormDB.Find(&chats)
 	if result.Error != nil {
@@ -290,44 +518,126 @@ func (db *Database) GetAllChats() ([]*Chat, error) {
 	return chats, nil
 }
 
-func (db *Database) UpdateChat(chat *Chat) (*Chat, error) {
-	result := db.gormDB.Model(&Chat{}).Select("name").Where("id = ?", chat.ID).Updates(chat)
-	if result.Error != nil {
-		return nil, fmt.Errorf("cannot update chat %#v: %w", chat, result.Error)
-	}
+func (db *Database) UpdateChat(ctx context.Context, chat *Chat) (*Chat, error) {
+	db = db.withContext(ctx)
+
+	var updatedChat *Chat
+	err := db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Model(&Chat{}).Select("name").Where("id = ?", chat.ID).Updates(chat)
+		if result.Error != nil {
+			return fmt.Errorf("cannot update chat %#v: %w", chat, result.Error)
+		}
+
+		var err error
+		updatedChat, err = tx.GetChat(ctx, chat.ID)
+		if err != nil {
+			return fmt.Errorf("tx.GetChat(%d): %w", chat.ID, err)
+		}
 
-	updatedChat, err := db.GetChat(chat.ID)
+		return tx.addOutboxEvent(AggregateChat, chat.ID, EventChatUpdated, updatedChat)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("db.GetChat(%d): %w", chat.ID, err)
+		return nil, err
 	}
 
 	return updatedChat, nil
 }
 
-func (db *Database) DeleteChat(id uint) error {
-	result := db.gormDB.Table("user_x_chat").Where("chat_id = ?", id).Delete(nil)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete users from chat with id = %d: %w", id, result.Error)
-	}
+func (db *Database) DeleteChat(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
 
-	result = db.gormDB.Model(&Message{}).Where("chat_id = ?", id).Delete(nil)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete users from chat with id = %d: %w", id, result.Error)
-	}
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Table("user_x_chat").Where("chat_id = ?", id).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete users from chat with id = %d: %w", id, result.Error)
+		}
 
-	result = db.gormDB.Delete(&Chat{}, id)
-	if result.Error != nil {
-		return fmt.Errorf("cannot delete chat with id = %d: %w", id, result.Error)
-	}
+		var messageIDs []uint
+		result = tx.gormDB.Model(&Message{}).Where("chat_id = ?", id).Pluck("id", &messageIDs)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get messages from chat with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Where("message_id IN ?", messageIDs).Delete(&Mention{})
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete mentions from chat with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Table("message_x_tag").Where("message_id IN ?", messageIDs).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete tags from chat with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Model(&Message{}).Where("chat_id = ?", id).Delete(nil)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete messages from chat with id = %d: %w", id, result.Error)
+		}
+
+		result = tx.gormDB.Where("chat_id = ?", id).Delete(&IncomingWebhook{})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot delete incoming webhooks of chat with id = %d: %w",
+				id,
+				result.Error,
+			)
+		}
+
+		result = tx.gormDB.Delete(&Chat{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("cannot delete chat with id = %d: %w", id, result.Error)
+		}
+
+		return tx.addOutboxEvent(AggregateChat, id, EventChatDeleted, &deletedEventData{ID: id})
+	})
+}
+
+func (db *Database) AddUserToChat(ctx context.Context, user *User, chat *Chat) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		err := tx.gormDB.Model(chat).Association("Members").Append(user)
+		if err != nil {
+			return fmt.Errorf(
+				"cannot add user with id = %d to chat with id = %d: %w",
+				user.ID,
+				chat.ID,
+				err,
+			)
+		}
+
+		err = tx.notify(&Notification{
+			UserID: user.ID,
+			Type:   NotificationChatMemberAdded,
+			ChatID: &chat.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("tx.notify(): %w", err)
+		}
 
-	return nil
+		return tx.addOutboxEvent(
+			AggregateChat,
+			chat.ID,
+			EventChatMemberAdded,
+			&chatMemberEventData{ChatID: chat.ID, UserID: user.ID},
+		)
+	})
 }
 
-func (db *Database) AddUserToChat(user *User, chat *Chat) error {
-	err := db.gormDB.Model(chat).Association("Members").Append(user)
-	if err != nil {
-		return fmt.Errorf("cannot add user %#v to chat %#v: %w", user, chat, err)
+func (db *Database) IsChatMember(ctx context.Context, chatID, userID uint) (bool, error) {
+	db = db.withContext(ctx)
+
+	var count int64
+	result := db.gormDB.Table("user_x_chat").
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf(
+			"cannot check member with id = %d of chat with id = %d: %w",
+			userID,
+			chatID,
+			result.Error,
+		)
 	}
 
-	return nil
+	return count > 0, nil
 }
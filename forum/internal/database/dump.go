@@ -0,0 +1,202 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotEmpty = errors.New("database already has users")
+
+// Dump is a portable copy of the users, bots, chats, posts and messages.
+// Mentions and hashtags are not included, they are rebuilt on import.
+type Dump struct {
+	Users    []DumpUser   `json:"users"`
+	Bots     []DumpBot    `json:"bots"`
+	Chats    []Chat       `json:"chats"`
+	Members  []ChatMember `json:"members"`
+	Posts    []Post       `json:"posts"`
+	Messages []Message    `json:"messages"`
+}
+
+type DumpUser struct {
+	User
+	HashPassword string     `json:"password"`
+	RemovedAt    *time.Time `json:"removed_at,omitempty"`
+}
+
+type DumpBot struct {
+	UserID     uint      `json:"user_id"`
+	WebhookURL string    `json:"webhook_url"`
+	Secret     string    `json:"secret"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ChatMember struct {
+	UserID uint `json:"user_id"`
+	ChatID uint `json:"chat_id"`
+}
+
+// Export reads a consistent copy of the forum content.
+func (db *Database) Export() (*Dump, error) {
+	dump := &Dump{}
+
+	err := db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY")
+		if result.Error != nil {
+			return fmt.Errorf("cannot start export snapshot: %w", result.Error)
+		}
+
+		var users []User
+		result = tx.gormDB.Order("id").Find(&users)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get users: %w", result.Error)
+		}
+
+		for _, user := range users {
+			dumpUser := DumpUser{User: user, HashPassword: user.HashPassword}
+			if user.RemovedAt.Valid {
+				dumpUser.RemovedAt = &user.RemovedAt.Time
+			}
+
+			dump.Users = append(dump.Users, dumpUser)
+		}
+
+		var bots []Bot
+		result = tx.gormDB.Order("user_id").Find(&bots)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get bots: %w", result.Error)
+		}
+
+		for _, bot := range bots {
+			dump.Bots = append(dump.Bots, DumpBot{
+				UserID:     bot.UserID,
+				WebhookURL: bot.WebhookURL,
+				Secret:     bot.Secret,
+				CreatedAt:  bot.CreatedAt,
+			})
+		}
+
+		result = tx.gormDB.Order("id").Find(&dump.Chats)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get chats: %w", result.Error)
+		}
+
+		result = tx.gormDB.Table("user_x_chat").Order("chat_id, user_id").Find(&dump.Members)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get chat members: %w", result.Error)
+		}
+
+		result = tx.gormDB.Order("id").Find(&dump.Posts)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get posts: %w", result.Error)
+		}
+
+		result = tx.gormDB.Order("id").Find(&dump.Messages)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get messages: %w", result.Error)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dump, nil
+}
+
+// Import writes the dump to a database without users, keeping the ids,
+// and rebuilds mentions and hashtags without notifying anyone.
+func (db *Database) Import(dump *Dump) error {
+	return db.Transaction(func(tx *Database) error {
+		tx.silent = true
+
+		var count int64
+		result := tx.gormDB.Model(&User{}).Count(&count)
+		if result.Error != nil {
+			return fmt.Errorf("cannot count users: %w", result.Error)
+		}
+
+		if count > 0 {
+			return ErrNotEmpty
+		}
+
+		for _, dumpUser := range dump.Users {
+			user := dumpUser.User
+			user.HashPassword = dumpUser.HashPassword
+			if dumpUser.RemovedAt != nil {
+				user.RemovedAt = sql.NullTime{Time: *dumpUser.RemovedAt, Valid: true}
+			}
+
+			result = tx.gormDB.Omit(clause.Associations).Create(&user)
+			if result.Error != nil {
+				return fmt.Errorf(
+					"cannot import user with id = %d: %w",
+					user.ID,
+					translateError(result.Error),
+				)
+			}
+		}
+
+		for _, dumpBot := range dump.Bots {
+			result = tx.gormDB.Omit(clause.Associations).Create(&Bot{
+				UserID:     dumpBot.UserID,
+				WebhookURL: dumpBot.WebhookURL,
+				Secret:     dumpBot.Secret,
+				CreatedAt:  dumpBot.CreatedAt,
+			})
+			if result.Error != nil {
+				return fmt.Errorf("cannot import bot with id = %d: %w", dumpBot.UserID, result.Error)
+			}
+		}
+
+		if len(dump.Chats) > 0 {
+			result = tx.gormDB.Omit(clause.Associations).Create(&dump.Chats)
+			if result.Error != nil {
+				return fmt.Errorf("cannot import chats: %w", result.Error)
+			}
+		}
+
+		if len(dump.Members) > 0 {
+			result = tx.gormDB.Table("user_x_chat").Create(&dump.Members)
+			if result.Error != nil {
+				return fmt.Errorf("cannot import chat members: %w", result.Error)
+			}
+		}
+
+		if len(dump.Posts) > 0 {
+			result = tx.gormDB.Omit(clause.Associations).Create(&dump.Posts)
+			if result.Error != nil {
+				return fmt.Errorf("cannot import posts: %w", result.Error)
+			}
+		}
+
+		if len(dump.Messages) > 0 {
+			result = tx.gormDB.Omit(clause.Associations).Create(&dump.Messages)
+			if result.Error != nil {
+				return fmt.Errorf("cannot import messages: %w", result.Error)
+			}
+		}
+
+		for _, table := range []string{"users", "chats", "posts", "messages"} {
+			result = tx.gormDB.Exec(fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) "+
+					"FROM %[1]s",
+				table,
+			))
+			if result.Error != nil {
+				return fmt.Errorf("cannot reset id sequence of %s: %w", table, result.Error)
+			}
+		}
+
+		if _, err := tx.Reindex(); err != nil {
+			return fmt.Errorf("tx.Reindex(): %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+func (db *Database) Ping(ctx context.Context) error {
+	sqlDB, err := db.gormDB.DB()
+	if err != nil {
+		return fmt.Errorf("cannot get sql db: %w", err)
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("cannot ping db: %w", err)
+	}
+
+	return nil
+}
+
+// PoolStats returns the statistics of the connection pool.
+func (db *Database) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := db.gormDB.DB()
+	if err != nil {
+		return sql.DBStats{}, fmt.Errorf("cannot get sql db: %w", err)
+	}
+
+	return sqlDB.Stats(), nil
+}
+
+// CountPendingMigrations returns how many embedded migrations are not applied.
+// Unlike MigrationStatus it does not wait for a running migration.
+func (db *Database) CountPendingMigrations(ctx context.Context) (int, error) {
+	db = db.withContext(ctx)
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("cannot load migrations: %w", err)
+	}
+
+	var applied []uint
+	result := db.gormDB.Model(&SchemaMigration{}).Pluck("version", &applied)
+	if result.Error != nil {
+		return 0, fmt.Errorf("cannot get applied migrations: %w", result.Error)
+	}
+
+	pending := 0
+	for _, migration := range migrations {
+		if !slices.Contains(applied, migration.Version) {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// GetOutboxBacklog returns the number of unpublished outbox events
+// and the creation time of the oldest one.
+func (db *Database) GetOutboxBacklog(ctx context.Context) (int64, *time.Time, error) {
+	db = db.withContext(ctx)
+
+	var backlog struct {
+		Count  int64
+		Oldest *time.Time
+	}
+
+	result := db.gormDB.Model(&OutboxEvent{}).
+		Select("COUNT(*) AS count, MIN(created_at) AS oldest").
+		Where("published_at IS NULL").
+		Scan(&backlog)
+	if result.Error != nil {
+		return 0, nil, fmt.Errorf("cannot get outbox backlog: %w", result.Error)
+	}
+
+	return backlog.Count, backlog.Oldest, nil
+}
+
+func (db *Database) CountPendingWebhookDeliveries(ctx context.Context) (int64, error) {
+	db = db.withContext(ctx)
+
+	var count int64
+	result := db.gormDB.Model(&WebhookDelivery{}).
+		Where("status = ?", DeliveryPending).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("cannot count pending webhook deliveries: %w", result.Error)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (db *Database) AddIncomingWebhook(ctx context.Context, hook *IncomingWebhook) error {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetChat(ctx, hook.ChatID); err != nil {
+		return fmt.Errorf("db.GetChat(%d): %w", hook.ChatID, err)
+	}
+
+	result := db.gormDB.Create(hook)
+	if result.Error != nil {
+		return fmt.Errorf("cannot add incoming webhook %q to db: %w", hook.Name, result.Error)
+	}
+
+	return nil
+}
+
+func (db *Database) GetChatIncomingWebhooks(
+	ctx context.Context,
+	chatID uint,
+) ([]IncomingWebhook, error) {
+	db = db.withContext(ctx)
+
+	var hooks []IncomingWebhook
+	result := db.gormDB.Where("chat_id = ?", chatID).Order("id").Find(&hooks)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get incoming webhooks of chat with id = %d: %w",
+			chatID,
+			result.Error,
+		)
+	}
+
+	return hooks, nil
+}
+
+// GetActiveIncomingWebhook returns the webhook with the token hash if it is not disabled.
+func (db *Database) GetActiveIncomingWebhook(tokenHash string) (*IncomingWebhook, error) {
+	hook := &IncomingWebhook{}
+	result := db.gormDB.Where("token_hash = ? AND disabled_at IS NULL", tokenHash).First(hook)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get active incoming webhook: %w", result.Error)
+	}
+
+	return hook, nil
+}
+
+func (db *Database) SetIncomingWebhookToken(id uint, tokenHash string) (*IncomingWebhook, error) {
+	return db.updateIncomingWebhook(id, map[string]any{"token_hash": tokenHash})
+}
+
+func (db *Database) SetIncomingWebhookDisabled(
+	ctx context.Context,
+	id uint,
+	disabled bool,
+) (*IncomingWebhook, error) {
+	db = db.withContext(ctx)
+
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+
+	return db.updateIncomingWebhook(id, map[string]any{"disabled_at": disabledAt})
+}
+
+func (db *Database) updateIncomingWebhook(id uint, values map[string]any) (*IncomingWebhook, error) {
+	result := db.gormDB.Model(&IncomingWebhook{}).Where("id = ?", id).Updates(values)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot update incoming webhook with id = %d: %w", id, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf(
+			"cannot update incoming webhook with id = %d: %w",
+			id,
+			gorm.ErrRecordNotFound,
+		)
+	}
+
+	hook := &IncomingWebhook{}
+	result = db.gormDB.Where("id = ?", id).First(hook)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get incoming webhook by id = %d: %w", id, result.Error)
+	}
+
+	return hook, nil
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/LLIEPJIOK/forum/internal/extract"
+	"gorm.io/gorm/clause"
+)
+
+// indexPost replaces mentions and hashtags of the post with the ones found in its content.
+func (db *Database) indexPost(post *Post) error {
+	var mentionedIDs []uint
+	result := db.gormDB.Model(&Mention{}).Where("post_id = ?", post.ID).Pluck("user_id", &mentionedIDs)
+	if result.Error != nil {
+		return fmt.Errorf("cannot get mentions of post with id = %d: %w", post.ID, result.Error)
+	}
+
+	result = db.gormDB.Where("post_id = ?", post.ID).Delete(&Mention{})
+	if result.Error != nil {
+		return fmt.Errorf("cannot delete mentions of post with id = %d: %w", post.ID, result.Error)
+	}
+
+	var userIDs []uint
+	if nicknames := extract.Mentions(post.Content); len(nicknames) > 0 {
+		result = db.gormDB.Model(&User{}).
+			Where("nickname IN ? AND removed_at IS NULL AND id <> ?", nicknames, post.AuthorID).
+			Pluck("id", &userIDs)
+		if result.Error != nil {
+			return fmt.Errorf("cannot resolve mentions of post with id = %d: %w", post.ID, result.Error)
+		}
+	}
+
+	mentions := make([]*Mention, 0, len(userIDs))
+	notifications := make([]*Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		mentions = append(mentions, &Mention{UserID: userID, PostID: &post.ID})
+
+		if !slices.Contains(mentionedIDs, userID) {
+			notifications = append(notifications, &Notification{
+				UserID:  userID,
+				Type:    NotificationMention,
+				ActorID: &post.AuthorID,
+				PostID:  &post.ID,
+			})
+		}
+	}
+
+	if err := db.addMentions(mentions); err != nil {
+		return fmt.Errorf("db.addMentions(): %w", err)
+	}
+
+	if err := db.notify(notifications...); err != nil {
+		return fmt.Errorf("db.notify(): %w", err)
+	}
+
+	tags, err := db.upsertTags(extract.Hashtags(post.Content))
+	if err != nil {
+		return fmt.Errorf("db.upsertTags(): %w", err)
+	}
+
+	if err := db.gormDB.Model(post).Association("Tags").Replace(tags); err != nil {
+		return fmt.Errorf("cannot set tags of post with id = %d: %w", post.ID, err)
+	}
+
+	return nil
+}
+
+// indexMessage replaces mentions and hashtags of the message with the ones found in its content.
+// Only members of the message chat can be mentioned.
+func (db *Database) indexMessage(message *Message) error {
+	var mentionedIDs []uint
+	result := db.gormDB.Model(&Mention{}).
+		Where("message_id = ?", message.ID).
+		Pluck("user_id", &mentionedIDs)
+	if result.Error != nil {
+		return fmt.Errorf("cannot get mentions of message with id = %d: %w", message.ID, result.Error)
+	}
+
+	result = db.gormDB.Where("message_id = ?", message.ID).Delete(&Mention{})
+	if result.Error != nil {
+		return fmt.Errorf("cannot delete mentions of message with id = %d: %w", message.ID, result.Error)
+	}
+
+	var userIDs []uint
+	if nicknames := extract.Mentions(message.Content); len(nicknames) > 0 {
+		result = db.gormDB.Model(&User{}).
+			Joins("JOIN user_x_chat ON user_x_chat.user_id = users.id").
+			Where("user_x_chat.chat_id = ?", message.ChatID).
+			Where("nickname IN ? AND removed_at IS NULL AND users.id <> ?", nicknames, message.SenderID).
+			Pluck("users.id", &userIDs)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot resolve mentions of message with id = %d: %w",
+				message.ID,
+				result.Error,
+			)
+		}
+	}
+
+	mentions := make([]*Mention, 0, len(userIDs))
+	notifications := make([]*Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		mentions = append(mentions, &Mention{UserID: userID, MessageID: &message.ID})
+
+		if !slices.Contains(mentionedIDs, userID) {
+			notifications = append(notifications, &Notification{
+				UserID:    userID,
+				Type:      NotificationMention,
+				ActorID:   &message.SenderID,
+				MessageID: &message.ID,
+				ChatID:    &message.ChatID,
+			})
+		}
+	}
+
+	if err := db.addMentions(mentions); err != nil {
+		return fmt.Errorf("db.addMentions(): %w", err)
+	}
+
+	if err := db.notify(notifications...); err != nil {
+		return fmt.Errorf("db.notify(): %w", err)
+	}
+
+	tags, err := db.upsertTags(extract.Hashtags(message.Content))
+	if err != nil {
+		return fmt.Errorf("db.upsertTags(): %w", err)
+	}
+
+	if err := db.gormDB.Model(message).Association("Tags").Replace(tags); err != nil {
+		return fmt.Errorf("cannot set tags of message with id = %d: %w", message.ID, err)
+	}
+
+	return nil
+}
+
+func (db *Database) addMentions(mentions []*Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	result := db.gormDB.Create(mentions)
+	if result.Error != nil {
+		return fmt.Errorf("cannot add mentions to db: %w", result.Error)
+	}
+
+	return nil
+}
+
+// upsertTags creates missing tags and returns all tags with the given names.
+func (db *Database) upsertTags(names []string) ([]Tag, error) {
+	if len(names) == 0 {
+		return []Tag{}, nil
+	}
+
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, Tag{Name: name})
+	}
+
+	result := db.gormDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&tags)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot add tags %q to db: %w", names, result.Error)
+	}
+
+	var stored []Tag
+	result = db.gormDB.Where("name IN ?", names).Find(&stored)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get tags %q: %w", names, result.Error)
+	}
+
+	return stored, nil
+}
+
+func (db *Database) GetUserMentions(ctx context.Context, userID uint) ([]*Mention, error) {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("db.GetUserByID(%d): %w", userID, err)
+	}
+
+	var mentions []*Mention
+	result := db.gormDB.Where("user_id = ?", userID).Order("created_at DESC").Find(&mentions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get mentions of user with id = %d: %w", userID, result.Error)
+	}
+
+	return mentions, nil
+}
+
+func (db *Database) GetPostsByTag(ctx context.Context, name string) ([]*Post, error) {
+	db = db.withContext(ctx)
+
+	tag := &Tag{}
+	result := db.gormDB.Where("name = ?", strings.ToLower(name)).First(tag)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get tag by name = %q: %w", name, result.Error)
+	}
+
+	var posts []*Post
+	if err := db.gormDB.Model(tag).Association("Posts").Find(&posts); err != nil {
+		return nil, fmt.Errorf("cannot get posts with tag %q: %w", name, err)
+	}
+
+	if err := db.refreshPostsHTML(posts...); err != nil {
+		return nil, fmt.Errorf("db.refreshPostsHTML(): %w", err)
+	}
+
+	return posts, nil
+}
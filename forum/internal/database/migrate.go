@@ -0,0 +1,284 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the advisory lock that keeps replicas from migrating at once.
+const migrationLockID = 7_340_042
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrNoMigrations = errors.New("no migrations to apply")
+
+// Migration is a versioned schema change. Files in the migrations directory
+// are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version uint
+	Name    string
+	up      string
+	down    string
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   uint      `gorm:"primarykey; autoIncrement:false"`
+	Name      string    `gorm:"not null;"`
+	AppliedAt time.Time `gorm:"not null;"`
+}
+
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Migrate applies all pending migrations.
+func (db *Database) Migrate() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("cannot load migrations: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if err := db.applyMigration(migration); err != nil {
+			return fmt.Errorf("db.applyMigration(%d): %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies the migration unless a replica did it before.
+// Every migration runs in its own transaction with the migration lock.
+func (db *Database) applyMigration(migration *Migration) error {
+	return db.Transaction(func(tx *Database) error {
+		applied, err := tx.lockMigrations()
+		if err != nil {
+			return fmt.Errorf("tx.lockMigrations(): %w", err)
+		}
+
+		if slices.ContainsFunc(applied, func(applied SchemaMigration) bool {
+			return applied.Version == migration.Version
+		}) {
+			return nil
+		}
+
+		result := tx.gormDB.Exec(migration.up)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot apply migration %d_%s: %w",
+				migration.Version,
+				migration.Name,
+				result.Error,
+			)
+		}
+
+		result = tx.gormDB.Create(&SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot record migration %d_%s: %w",
+				migration.Version,
+				migration.Name,
+				result.Error,
+			)
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of the latest applied migrations.
+func (db *Database) MigrateDown(steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("cannot load migrations: %w", err)
+	}
+
+	for range steps {
+		if err := db.rollbackMigration(migrations); err != nil {
+			return fmt.Errorf("db.rollbackMigration(): %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *Database) rollbackMigration(migrations []*Migration) error {
+	return db.Transaction(func(tx *Database) error {
+		applied, err := tx.lockMigrations()
+		if err != nil {
+			return fmt.Errorf("tx.lockMigrations(): %w", err)
+		}
+
+		if len(applied) == 0 {
+			return ErrNoMigrations
+		}
+
+		last := applied[len(applied)-1]
+		i := slices.IndexFunc(migrations, func(migration *Migration) bool {
+			return migration.Version == last.Version
+		})
+		if i == -1 {
+			return fmt.Errorf("cannot find migration %d_%s", last.Version, last.Name)
+		}
+
+		result := tx.gormDB.Exec(migrations[i].down)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot roll back migration %d_%s: %w",
+				last.Version,
+				last.Name,
+				result.Error,
+			)
+		}
+
+		result = tx.gormDB.Delete(&SchemaMigration{}, last.Version)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot delete record of migration %d_%s: %w",
+				last.Version,
+				last.Name,
+				result.Error,
+			)
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus returns all known migrations with the time they were applied.
+func (db *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load migrations: %w", err)
+	}
+
+	var applied []SchemaMigration
+	err = db.Transaction(func(tx *Database) error {
+		var err error
+		applied, err = tx.lockMigrations()
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tx.lockMigrations(): %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		for _, applied := range applied {
+			if applied.Version == migration.Version {
+				status.AppliedAt = &applied.AppliedAt
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// lockMigrations takes the migration lock for the rest of the transaction
+// and returns the applied migrations ordered by version.
+func (db *Database) lockMigrations() ([]SchemaMigration, error) {
+	result := db.gormDB.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot take migration lock: %w", result.Error)
+	}
+
+	result = db.gormDB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot create schema_migrations table: %w", result.Error)
+	}
+
+	var applied []SchemaMigration
+	result = db.gormDB.Order("version").Find(&applied)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get applied migrations: %w", result.Error)
+	}
+
+	return applied, nil
+}
+
+// loadMigrations reads the embedded migrations ordered by version.
+func loadMigrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		rawVersion, name, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(rawVersion, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration file %q: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration file %q: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migrations %q and %q have the same version", migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf(
+				"migration %d_%s must have up and down files",
+				migration.Version,
+				migration.Name,
+			)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+
+	return migrations, nil
+}
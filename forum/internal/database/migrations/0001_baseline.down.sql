@@ -0,0 +1,26 @@
+DROP TABLE IF EXISTS
+    outbox_events,
+    incoming_webhooks,
+    webhook_deliveries,
+    webhook_subscriptions,
+    bots,
+    api_keys,
+    rate_limit_buckets,
+    login_throttles,
+    two_factor_policies,
+    recovery_codes,
+    two_factors,
+    password_reset_tokens,
+    sessions,
+    verification_tokens,
+    notification_preferences,
+    notifications,
+    message_x_tag,
+    post_x_tag,
+    tags,
+    mentions,
+    user_x_chat,
+    messages,
+    posts,
+    chats,
+    users;
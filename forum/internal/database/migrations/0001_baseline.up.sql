@@ -0,0 +1,266 @@
+-- Baseline of the schema created by gorm AutoMigrate. Every statement is idempotent,
+-- so databases created before versioned migrations are marked as migrated.
+-- Tables of older databases get the columns added since they were created.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    nickname text NOT NULL,
+    email text CONSTRAINT uni_users_email UNIQUE,
+    hash_password text NOT NULL,
+    registered_at timestamptz,
+    removed_at timestamptz,
+    verified_at timestamptz,
+    role text NOT NULL DEFAULT 'user',
+    is_bot boolean NOT NULL DEFAULT false
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS verified_at timestamptz,
+    ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user',
+    ADD COLUMN IF NOT EXISTS is_bot boolean NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_bot_nickname ON users (nickname)
+    WHERE is_bot AND removed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS chats (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id bigserial PRIMARY KEY,
+    content text NOT NULL,
+    content_html text NOT NULL DEFAULT '',
+    revision bigint NOT NULL DEFAULT 1,
+    html_revision bigint NOT NULL DEFAULT 0,
+    author_id bigint CONSTRAINT fk_users_posts REFERENCES users (id),
+    created_at timestamptz
+);
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS revision bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS html_revision bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS messages (
+    id bigserial PRIMARY KEY,
+    content text NOT NULL,
+    content_html text NOT NULL DEFAULT '',
+    revision bigint NOT NULL DEFAULT 1,
+    html_revision bigint NOT NULL DEFAULT 0,
+    sender_id bigint CONSTRAINT fk_users_messages REFERENCES users (id),
+    sender_name text NOT NULL DEFAULT '',
+    chat_id bigint CONSTRAINT fk_chats_messages REFERENCES chats (id),
+    sended_at timestamptz
+);
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS revision bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS html_revision bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS sender_name text NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS user_x_chat (
+    user_id bigint CONSTRAINT fk_user_x_chat_user REFERENCES users (id),
+    chat_id bigint CONSTRAINT fk_user_x_chat_chat REFERENCES chats (id),
+    PRIMARY KEY (user_id, chat_id)
+);
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    post_id bigint,
+    message_id bigint,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
+CREATE INDEX IF NOT EXISTS idx_mentions_post_id ON mentions (post_id);
+CREATE INDEX IF NOT EXISTS idx_mentions_message_id ON mentions (message_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL CONSTRAINT uni_tags_name UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS post_x_tag (
+    post_id bigint CONSTRAINT fk_post_x_tag_post REFERENCES posts (id),
+    tag_id bigint CONSTRAINT fk_post_x_tag_tag REFERENCES tags (id),
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS message_x_tag (
+    message_id bigint CONSTRAINT fk_message_x_tag_message REFERENCES messages (id),
+    tag_id bigint CONSTRAINT fk_message_x_tag_tag REFERENCES tags (id),
+    PRIMARY KEY (message_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    type text NOT NULL,
+    actor_id bigint,
+    post_id bigint,
+    message_id bigint,
+    chat_id bigint,
+    read_at timestamptz,
+    emailed_at timestamptz,
+    created_at timestamptz
+);
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS emailed_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id bigserial PRIMARY KEY,
+    email_mode text NOT NULL DEFAULT 'daily',
+    last_digest_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS verification_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON verification_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL CONSTRAINT uni_sessions_token_hash UNIQUE,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL CONSTRAINT uni_password_reset_tokens_token_hash UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS two_factors (
+    user_id bigserial PRIMARY KEY,
+    secret text NOT NULL,
+    confirmed_at timestamptz,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS two_factor_policies (
+    role text PRIMARY KEY,
+    required boolean NOT NULL DEFAULT false,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key text PRIMARY KEY,
+    failures bigint NOT NULL DEFAULT 0,
+    last_failure_at timestamptz NOT NULL,
+    locked_until timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key text PRIMARY KEY,
+    tokens decimal NOT NULL DEFAULT 0,
+    refilled_at timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    key_hash text NOT NULL CONSTRAINT uni_api_keys_key_hash UNIQUE,
+    scopes text NOT NULL,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS bots (
+    user_id bigserial PRIMARY KEY CONSTRAINT fk_bots_user REFERENCES users (id),
+    webhook_url text NOT NULL DEFAULT '',
+    secret text NOT NULL DEFAULT '',
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    events text NOT NULL,
+    secret text NOT NULL,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    subscription_id bigint NOT NULL
+        CONSTRAINT fk_webhook_deliveries_subscription REFERENCES webhook_subscriptions (id),
+    event text NOT NULL,
+    payload text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts bigint NOT NULL DEFAULT 0,
+    status_code bigint NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    next_attempt_at timestamptz,
+    delivered_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id
+    ON webhook_deliveries (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at
+    ON webhook_deliveries (next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id bigserial PRIMARY KEY,
+    chat_id bigint NOT NULL,
+    name text NOT NULL,
+    token_hash text NOT NULL CONSTRAINT uni_incoming_webhooks_token_hash UNIQUE,
+    disabled_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_chat_id ON incoming_webhooks (chat_id);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id bigserial PRIMARY KEY,
+    aggregate_type text NOT NULL,
+    aggregate_id bigint NOT NULL,
+    event text NOT NULL,
+    data text NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    published_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_aggregate ON outbox_events (aggregate_type, aggregate_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
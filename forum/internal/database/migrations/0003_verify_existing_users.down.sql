@@ -0,0 +1,4 @@
+UPDATE users SET verified_at = NULL
+WHERE verified_at = registered_at
+    AND NOT is_bot
+    AND NOT EXISTS (SELECT 1 FROM verification_tokens WHERE user_id = users.id);
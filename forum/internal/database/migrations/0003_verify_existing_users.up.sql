@@ -0,0 +1,6 @@
+-- Accounts registered before email verification never got a verification token,
+-- they are verified from their registration.
+UPDATE users SET verified_at = registered_at
+WHERE verified_at IS NULL
+    AND NOT is_bot
+    AND NOT EXISTS (SELECT 1 FROM verification_tokens WHERE user_id = users.id);
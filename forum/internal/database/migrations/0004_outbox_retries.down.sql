@@ -0,0 +1,3 @@
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS failed_at;
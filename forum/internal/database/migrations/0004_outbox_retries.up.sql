@@ -0,0 +1,3 @@
+ALTER TABLE outbox_events
+    ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz,
+    ADD COLUMN IF NOT EXISTS failed_at timestamptz;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_rate_limit_buckets_full_at;
+
+ALTER TABLE rate_limit_buckets DROP COLUMN IF EXISTS full_at;
@@ -0,0 +1,5 @@
+-- Existing buckets are removed by the next sweep, like buckets that are full again.
+ALTER TABLE rate_limit_buckets ADD COLUMN IF NOT EXISTS full_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE rate_limit_buckets ALTER COLUMN full_at DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_chats_owner_id;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS owner_id;
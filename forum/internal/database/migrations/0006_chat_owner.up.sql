@@ -0,0 +1,5 @@
+-- Chats created before owners have none, only admins manage them.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS owner_id bigint
+    CONSTRAINT fk_chats_owner REFERENCES users (id);
+
+CREATE INDEX IF NOT EXISTS idx_chats_owner_id ON chats (owner_id);
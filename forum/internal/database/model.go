@@ -5,37 +5,285 @@ import (
 	"time"
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
 type User struct {
 	ID           uint         `gorm:"primarykey; autoIncrement" json:"id"`
-	Nickname     string       `gorm:"not null;" json:"nickname"`
+	Nickname     string       `gorm:"not null; uniqueIndex:idx_users_bot_nickname,where:is_bot AND removed_at IS NULL" json:"nickname"`
 	Email        *string      `gorm:"unique;" json:"email"`
-	HashPassword string       `gorm:"not null;" json:"password"`
+	HashPassword string       `gorm:"not null;" json:"-"`
 	RegisteredAt time.Time    `gorm:"autoCreateTime" json:"registered_at"`
 	RemovedAt    sql.NullTime `json:"-"`
+	VerifiedAt   *time.Time   `json:"verified_at"`
+	Role         string       `gorm:"not null; default:user" json:"role"`
+	DisabledAt   *time.Time   `json:"disabled_at,omitempty"`
+	IsBot        bool         `gorm:"not null; default:false" json:"is_bot"`
 	Posts        []Post       `gorm:"foreignKey:AuthorID;" json:"-"`
 	Messages     []Message    `gorm:"foreignKey:SenderID;" json:"-"`
 	Chats        []Chat       `gorm:"many2many:user_x_chat;" json:"-"`
 }
 
 type Post struct {
-	ID        uint      `gorm:"primarykey; autoIncrement" json:"id"`
-	Content   string    `gorm:"not null;" json:"content"`
-	AuthorID  uint      `json:"author_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           uint      `gorm:"primarykey; autoIncrement" json:"id"`
+	Content      string    `gorm:"not null;" json:"content"`
+	ContentHTML  string    `gorm:"not null; default:''" json:"content_html"`
+	Revision     uint      `gorm:"not null; default:1" json:"revision"`
+	HTMLRevision uint      `gorm:"not null; default:0" json:"-"`
+	AuthorID     uint      `json:"author_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Tags         []Tag     `gorm:"many2many:post_x_tag;" json:"-"`
 }
 
 type Message struct {
-	ID       uint      `gorm:"primarykey; autoIncrement" json:"id"`
-	Content  string    `gorm:"not null;" json:"content"`
-	SenderID uint      `json:"sender_id"`
-	ChatID   uint      `json:"chat_id"`
-	SendedAt time.Time `gorm:"autoCreateTime" json:"sended_at"`
+	ID           uint      `gorm:"primarykey; autoIncrement" json:"id"`
+	Content      string    `gorm:"not null;" json:"content"`
+	ContentHTML  string    `gorm:"not null; default:''" json:"content_html"`
+	Revision     uint      `gorm:"not null; default:1" json:"revision"`
+	HTMLRevision uint      `gorm:"not null; default:0" json:"-"`
+	SenderID     uint      `json:"sender_id"`
+	SenderName   string    `gorm:"not null; default:''" json:"sender_name,omitempty"`
+	ChatID       uint      `json:"chat_id"`
+	SendedAt     time.Time `gorm:"autoCreateTime" json:"sended_at"`
+	Tags         []Tag     `gorm:"many2many:message_x_tag;" json:"-"`
 }
 
+// Chat is managed by its owner, who is its first member.
 type Chat struct {
 	ID        uint      `gorm:"primarykey; autoIncrement" json:"id"`
 	Name      string    `gorm:"not null;" json:"name"`
+	OwnerID   *uint     `gorm:"index" json:"owner_id"`
 	CreatedAt time.Time `json:"created_at"`
 	Members   []User    `gorm:"many2many:user_x_chat;" json:"-"`
 	Messages  []Message `gorm:"foreignKey:ChatID;" json:"-"`
 }
+
+type Mention struct {
+	ID        uint      `gorm:"primarykey; autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null; index" json:"user_id"`
+	PostID    *uint     `gorm:"index" json:"post_id,omitempty"`
+	MessageID *uint     `gorm:"index" json:"message_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Tag struct {
+	ID       uint      `gorm:"primarykey; autoIncrement" json:"id"`
+	Name     string    `gorm:"not null; unique" json:"name"`
+	Posts    []Post    `gorm:"many2many:post_x_tag;" json:"-"`
+	Messages []Message `gorm:"many2many:message_x_tag;" json:"-"`
+}
+
+const (
+	NotificationMention         = "mention"
+	NotificationChatMemberAdded = "chat_member_added"
+)
+
+type Notification struct {
+	ID        uint       `gorm:"primarykey; autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null; index" json:"user_id"`
+	Type      string     `gorm:"not null;" json:"type"`
+	ActorID   *uint      `json:"actor_id,omitempty"`
+	PostID    *uint      `json:"post_id,omitempty"`
+	MessageID *uint      `json:"message_id,omitempty"`
+	ChatID    *uint      `json:"chat_id,omitempty"`
+	ReadAt    *time.Time `json:"read_at"`
+	EmailedAt *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+const (
+	EmailImmediate   = "immediate"
+	EmailDailyDigest = "daily"
+	EmailOff         = "off"
+)
+
+type NotificationPreference struct {
+	UserID       uint       `gorm:"primarykey" json:"user_id"`
+	EmailMode    string     `gorm:"not null; default:daily" json:"email_mode"`
+	LastDigestAt *time.Time `json:"-"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type VerificationToken struct {
+	ID        uint      `gorm:"primarykey; autoIncrement"`
+	UserID    uint      `gorm:"not null; index"`
+	ExpiresAt time.Time `gorm:"not null;"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type Session struct {
+	ID        uint       `gorm:"primarykey; autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null; index" json:"user_id"`
+	TokenHash string     `gorm:"not null; unique" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;" json:"expires_at"`
+	RevokedAt *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey; autoIncrement"`
+	UserID    uint      `gorm:"not null; index"`
+	TokenHash string    `gorm:"not null; unique"`
+	ExpiresAt time.Time `gorm:"not null;"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type TwoFactor struct {
+	UserID       uint   `gorm:"primarykey"`
+	Secret       string `gorm:"not null;"`
+	ConfirmedAt  *time.Time
+	LastUsedStep int64 `gorm:"not null; default:0"`
+	CreatedAt    time.Time
+}
+
+type RecoveryCode struct {
+	ID       uint   `gorm:"primarykey; autoIncrement"`
+	UserID   uint   `gorm:"not null; index"`
+	CodeHash string `gorm:"not null;"`
+	UsedAt   *time.Time
+}
+
+type TwoFactorPolicy struct {
+	Role      string    `gorm:"primarykey" json:"role"`
+	Required  bool      `gorm:"not null; default:false" json:"required"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoginThrottle counts recent failed logins for an account or a client IP.
+type LoginThrottle struct {
+	Key           string    `gorm:"primarykey"`
+	Failures      int       `gorm:"not null; default:0"`
+	LastFailureAt time.Time `gorm:"not null;"`
+	LockedUntil   *time.Time
+}
+
+// RateLimitBucket is a token bucket of the rate limiter shared by all replicas.
+// At FullAt the bucket is full again and can be removed.
+type RateLimitBucket struct {
+	Key        string    `gorm:"primarykey"`
+	Tokens     float64   `gorm:"not null; default:0"`
+	RefilledAt time.Time `gorm:"not null;"`
+	FullAt     time.Time `gorm:"not null; index"`
+}
+
+// APIKey lets a bot or an integration act as its user within the scopes.
+// Only the hash of the key is stored, the prefix identifies it to the user.
+type APIKey struct {
+	ID         uint       `gorm:"primarykey; autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null; index" json:"user_id"`
+	Name       string     `gorm:"not null;" json:"name"`
+	Prefix     string     `gorm:"not null;" json:"prefix"`
+	KeyHash    string     `gorm:"not null; unique" json:"-"`
+	Scopes     []string   `gorm:"not null; serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Bot is a bot account. Commands in the chats of the bot go to its webhook
+// or, without one, to the in-process handler registered for its nickname.
+type Bot struct {
+	UserID     uint      `gorm:"primarykey" json:"user_id"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+	WebhookURL string    `gorm:"not null; default:''" json:"webhook_url"`
+	Secret     string    `gorm:"not null; default:''" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeleted     = "user.deleted"
+	EventPostCreated     = "post.created"
+	EventPostUpdated     = "post.updated"
+	EventPostDeleted     = "post.deleted"
+	EventMessageCreated  = "message.created"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventChatCreated     = "chat.created"
+	EventChatUpdated     = "chat.updated"
+	EventChatDeleted     = "chat.deleted"
+	EventChatMemberAdded = "chat.member_added"
+)
+
+var Events = []string{
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventPostCreated,
+	EventPostUpdated,
+	EventPostDeleted,
+	EventMessageCreated,
+	EventMessageUpdated,
+	EventMessageDeleted,
+	EventChatCreated,
+	EventChatUpdated,
+	EventChatDeleted,
+	EventChatMemberAdded,
+}
+
+// WebhookSubscription gets the events of the types as signed JSON posts to the URL.
+type WebhookSubscription struct {
+	ID        uint      `gorm:"primarykey; autoIncrement" json:"id"`
+	URL       string    `gorm:"not null;" json:"url"`
+	Events    []string  `gorm:"not null; serializer:json" json:"events"`
+	Secret    string    `gorm:"not null;" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is an event sent to a subscription, it is kept as the delivery log.
+type WebhookDelivery struct {
+	ID             uint                `gorm:"primarykey; autoIncrement" json:"id"`
+	SubscriptionID uint                `gorm:"not null; index" json:"subscription_id"`
+	Subscription   WebhookSubscription `gorm:"foreignKey:SubscriptionID" json:"-"`
+	Event          string              `gorm:"not null;" json:"event"`
+	Payload        string              `gorm:"not null;" json:"payload"`
+	Status         string              `gorm:"not null; default:pending; index" json:"status"`
+	Attempts       int                 `gorm:"not null; default:0" json:"attempts"`
+	StatusCode     int                 `gorm:"not null; default:0" json:"status_code"`
+	LastError      string              `gorm:"not null; default:''" json:"last_error"`
+	NextAttemptAt  *time.Time          `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time          `json:"delivered_at"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+// IncomingWebhook lets external systems post messages to the chat with a secret token.
+type IncomingWebhook struct {
+	ID         uint       `gorm:"primarykey; autoIncrement" json:"id"`
+	ChatID     uint       `gorm:"not null; index" json:"chat_id"`
+	Name       string     `gorm:"not null;" json:"name"`
+	TokenHash  string     `gorm:"not null; unique" json:"-"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// OutboxEvent is an event written in the same transaction as the change it describes.
+// The relay publishes events of an aggregate in the order of their ids. Failed events
+// are retried at NextAttemptAt and parked with FailedAt after the last attempt.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primarykey; autoIncrement" json:"id"`
+	AggregateType string     `gorm:"not null; index:idx_outbox_aggregate" json:"aggregate_type"`
+	AggregateID   uint       `gorm:"not null; index:idx_outbox_aggregate" json:"aggregate_id"`
+	Event         string     `gorm:"not null;" json:"event"`
+	Data          string     `gorm:"not null;" json:"data"`
+	Attempts      int        `gorm:"not null; default:0" json:"attempts"`
+	LastError     string     `gorm:"not null; default:''" json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	FailedAt      *time.Time `json:"failed_at"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
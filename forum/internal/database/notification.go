@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationPublisher delivers stored notifications to connected clients.
+type NotificationPublisher interface {
+	Publish(notification *Notification)
+}
+
+// notify stores notifications and publishes them for real-time delivery.
+// In a transaction they are published after the commit.
+func (db *Database) notify(notifications ...*Notification) error {
+	if len(notifications) == 0 || db.silent {
+		return nil
+	}
+
+	result := db.gormDB.Create(notifications)
+	if result.Error != nil {
+		return fmt.Errorf("cannot add notifications to db: %w", result.Error)
+	}
+
+	if db.pending != nil {
+		*db.pending = append(*db.pending, notifications...)
+	} else {
+		db.publish(notifications...)
+	}
+
+	return nil
+}
+
+func (db *Database) publish(notifications ...*Notification) {
+	if db.publisher == nil {
+		return
+	}
+
+	for _, notification := range notifications {
+		db.publisher.Publish(notification)
+	}
+}
+
+func (db *Database) GetNotifications(
+	ctx context.Context,
+	userID uint,
+	unreadOnly bool,
+) ([]*Notification, error) {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("db.GetUserByID(%d): %w", userID, err)
+	}
+
+	query := db.gormDB.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []*Notification
+	result := query.Order("created_at DESC").Find(&notifications)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get notifications of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return notifications, nil
+}
+
+// GetNotification returns the notification of the user.
+func (db *Database) GetNotification(userID, id uint) (*Notification, error) {
+	notification := &Notification{}
+	result := db.gormDB.Where("id = ? AND user_id = ?", id, userID).First(notification)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get notification by id = %d: %w", id, result.Error)
+	}
+
+	return notification, nil
+}
+
+// MarkNotificationRead marks the notification of the user as read.
+// Notifications of other users are not found.
+func (db *Database) MarkNotificationRead(
+	ctx context.Context,
+	userID, id uint,
+) (*Notification, error) {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot mark notification with id = %d as read: %w", id, result.Error)
+	}
+
+	notification, err := db.GetNotification(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetNotification(%d, %d): %w", userID, id, err)
+	}
+
+	return notification, nil
+}
+
+func (db *Database) MarkAllNotificationsRead(ctx context.Context, userID uint) error {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetUserByID(ctx, userID); err != nil {
+		return fmt.Errorf("db.GetUserByID(%d): %w", userID, err)
+	}
+
+	result := db.gormDB.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot mark notifications of user with id = %d as read: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+func (db *Database) GetNotificationPreference(
+	ctx context.Context,
+	userID uint,
+) (*NotificationPreference, error) {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("db.GetUserByID(%d): %w", userID, err)
+	}
+
+	preference := &NotificationPreference{}
+	result := db.gormDB.Where("user_id = ?", userID).First(preference)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return &NotificationPreference{UserID: userID, EmailMode: EmailDailyDigest}, nil
+	} else if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get notification preference of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return preference, nil
+}
+
+func (db *Database) SetNotificationPreference(
+	ctx context.Context,
+	preference *NotificationPreference,
+) (*NotificationPreference, error) {
+	db = db.withContext(ctx)
+
+	if _, err := db.GetUserByID(ctx, preference.UserID); err != nil {
+		return nil, fmt.Errorf("db.GetUserByID(%d): %w", preference.UserID, err)
+	}
+
+	result := db.gormDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email_mode", "updated_at"}),
+	}).Create(preference)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot set notification preference %#v: %w",
+			preference,
+			result.Error,
+		)
+	}
+
+	updatedPreference, err := db.GetNotificationPreference(ctx, preference.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetNotificationPreference(%d): %w", preference.UserID, err)
+	}
+
+	return updatedPreference, nil
+}
+
+// GetDailyDigestUsers returns active users with an email who receive daily digests
+// and did not get one since sentBefore.
+func (db *Database) GetDailyDigestUsers(sentBefore time.Time) ([]*User, error) {
+	var users []*User
+	result := db.gormDB.Select("users.*").
+		Joins("LEFT JOIN notification_preferences ON notification_preferences.user_id = users.id").
+		Where("users.removed_at IS NULL AND users.email IS NOT NULL").
+		Where(
+			"COALESCE(notification_preferences.email_mode, ?) = ?",
+			EmailDailyDigest,
+			EmailDailyDigest,
+		).
+		Where(
+			"notification_preferences.last_digest_at IS NULL OR notification_preferences.last_digest_at < ?",
+			sentBefore,
+		).
+		Find(&users)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get daily digest users: %w", result.Error)
+	}
+
+	return users, nil
+}
+
+// GetDigestNotifications returns unread notifications of the user created after since
+// that were not emailed immediately.
+func (db *Database) GetDigestNotifications(userID uint, since time.Time) ([]*Notification, error) {
+	var notifications []*Notification
+	result := db.gormDB.
+		Where("user_id = ? AND read_at IS NULL AND emailed_at IS NULL", userID).
+		Where("created_at > ?", since).
+		Order("created_at").
+		Find(&notifications)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get digest notifications of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return notifications, nil
+}
+
+func (db *Database) MarkDigestSent(userID uint, sentAt time.Time) error {
+	result := db.gormDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_digest_at"}),
+	}).Create(&NotificationPreference{
+		UserID:       userID,
+		EmailMode:    EmailDailyDigest,
+		LastDigestAt: &sentAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot mark digest of user with id = %d as sent: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// GetPendingImmediateNotifications returns unread notifications that were not emailed yet
+// to active users who want them immediately. Notifications created before the user
+// switched to immediate emails are left out.
+func (db *Database) GetPendingImmediateNotifications() ([]*Notification, error) {
+	var notifications []*Notification
+	result := db.gormDB.Select("notifications.*").
+		Joins("JOIN notification_preferences ON notification_preferences.user_id = notifications.user_id").
+		Joins("JOIN users ON users.id = notifications.user_id").
+		Where("notification_preferences.email_mode = ?", EmailImmediate).
+		Where("notifications.created_at >= notification_preferences.updated_at").
+		Where("notifications.read_at IS NULL AND notifications.emailed_at IS NULL").
+		Where("users.removed_at IS NULL AND users.email IS NOT NULL").
+		Order("notifications.created_at").
+		Find(&notifications)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get pending immediate notifications: %w", result.Error)
+	}
+
+	return notifications, nil
+}
+
+func (db *Database) MarkNotificationEmailed(id uint, emailedAt time.Time) error {
+	result := db.gormDB.Model(&Notification{}).Where("id = ?", id).Update("emailed_at", emailedAt)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot mark notification with id = %d as emailed: %w",
+			id,
+			result.Error,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AggregateUser    = "user"
+	AggregatePost    = "post"
+	AggregateMessage = "message"
+	AggregateChat    = "chat"
+)
+
+const (
+	// outboxLockID is the advisory lock that lets a single relay publish events at a time,
+	// so events of an aggregate are never published out of order.
+	outboxLockID = 7_340_040
+
+	outboxMaxAttempts = 10
+	outboxBaseBackoff = 5 * time.Second
+)
+
+// addOutboxEvent writes the event. It must be called in the transaction
+// of the change the event describes.
+func (db *Database) addOutboxEvent(
+	aggregateType string,
+	aggregateID uint,
+	event string,
+	data any,
+) error {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal %q event data: %w", event, err)
+	}
+
+	result := db.gormDB.Create(&OutboxEvent{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Event:         event,
+		Data:          string(rawData),
+	})
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot add %q event of %s with id = %d to outbox: %w",
+			event,
+			aggregateType,
+			aggregateID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// RelayOutbox passes up to limit unpublished events to publish in the order they were
+// written and marks the published ones. Failed events are retried with exponential
+// backoff and parked after the last attempt, the later events of the same aggregate
+// wait for them. It returns the number of published events, or zero if another relay
+// holds the lock.
+func (db *Database) RelayOutbox(limit int, publish func(event *OutboxEvent) error) (int, error) {
+	published := 0
+	now := time.Now()
+
+	err := db.Transaction(func(tx *Database) error {
+		var locked bool
+		result := tx.gormDB.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockID).Scan(&locked)
+		if result.Error != nil {
+			return fmt.Errorf("cannot lock outbox: %w", result.Error)
+		}
+
+		if !locked {
+			return nil
+		}
+
+		var events []*OutboxEvent
+		result = tx.gormDB.
+			Where("published_at IS NULL AND failed_at IS NULL").
+			Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM outbox_events AS earlier
+				WHERE earlier.aggregate_type = outbox_events.aggregate_type
+					AND earlier.aggregate_id = outbox_events.aggregate_id
+					AND earlier.id < outbox_events.id
+					AND earlier.published_at IS NULL
+					AND (earlier.failed_at IS NOT NULL OR earlier.next_attempt_at > ?)
+			)`, now).
+			Order("id").
+			Limit(limit).
+			Find(&events)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get unpublished outbox events: %w", result.Error)
+		}
+
+		failed := make(map[string]bool)
+		for _, event := range events {
+			aggregate := fmt.Sprintf("%s:%d", event.AggregateType, event.AggregateID)
+			if failed[aggregate] {
+				continue
+			}
+
+			attempts := event.Attempts + 1
+			values := map[string]any{"attempts": attempts}
+			if err := publish(event); err != nil {
+				failed[aggregate] = true
+				values["last_error"] = err.Error()
+
+				if attempts >= outboxMaxAttempts {
+					values["failed_at"] = time.Now()
+					values["next_attempt_at"] = nil
+				} else {
+					values["next_attempt_at"] = time.Now().Add(outboxBaseBackoff << (attempts - 1))
+				}
+			} else {
+				published++
+				values["published_at"] = time.Now()
+				values["next_attempt_at"] = nil
+				values["last_error"] = ""
+			}
+
+			result := tx.gormDB.Model(&OutboxEvent{}).Where("id = ?", event.ID).Updates(values)
+			if result.Error != nil {
+				return fmt.Errorf("cannot update outbox event with id = %d: %w", event.ID, result.Error)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot relay outbox: %w", err)
+	}
+
+	return published, nil
+}
+
+// DeletePublishedOutboxEvents removes the events published before the time.
+func (db *Database) DeletePublishedOutboxEvents(before time.Time) error {
+	result := db.gormDB.Where("published_at < ?", before).Delete(&OutboxEvent{})
+	if result.Error != nil {
+		return fmt.Errorf("cannot delete published outbox events: %w", result.Error)
+	}
+
+	return nil
+}
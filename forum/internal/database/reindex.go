@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const reindexBatchSize = 100
+
+type ReindexResult struct {
+	Posts    int `json:"posts"`
+	Messages int `json:"messages"`
+}
+
+// Reindex renders all posts and messages again and rebuilds their mentions and hashtags.
+// Users mentioned again are not notified.
+func (db *Database) Reindex() (*ReindexResult, error) {
+	reindexed := &ReindexResult{}
+
+	err := db.Transaction(func(tx *Database) error {
+		tx.silent = true
+
+		var posts []*Post
+		result := tx.gormDB.FindInBatches(&posts, reindexBatchSize, func(*gorm.DB, int) error {
+			for _, post := range posts {
+				post.HTMLRevision = 0
+			}
+
+			if err := tx.refreshPostsHTML(posts...); err != nil {
+				return fmt.Errorf("tx.refreshPostsHTML(): %w", err)
+			}
+
+			for _, post := range posts {
+				if err := tx.indexPost(post); err != nil {
+					return fmt.Errorf("tx.indexPost(%d): %w", post.ID, err)
+				}
+			}
+
+			reindexed.Posts += len(posts)
+
+			return nil
+		})
+		if result.Error != nil {
+			return fmt.Errorf("cannot reindex posts: %w", result.Error)
+		}
+
+		var messages []*Message
+		result = tx.gormDB.FindInBatches(&messages, reindexBatchSize, func(*gorm.DB, int) error {
+			for _, message := range messages {
+				message.HTMLRevision = 0
+			}
+
+			if err := tx.refreshMessagesHTML(messages...); err != nil {
+				return fmt.Errorf("tx.refreshMessagesHTML(): %w", err)
+			}
+
+			for _, message := range messages {
+				if err := tx.indexMessage(message); err != nil {
+					return fmt.Errorf("tx.indexMessage(%d): %w", message.ID, err)
+				}
+			}
+
+			reindexed.Messages += len(messages)
+
+			return nil
+		})
+		if result.Error != nil {
+			return fmt.Errorf("cannot reindex messages: %w", result.Error)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reindexed, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/LLIEPJIOK/forum/internal/markdown"
+)
+
+// refreshPostsHTML renders posts whose cached HTML is older than their current revision,
+// so every revision is rendered only once.
+func (db *Database) refreshPostsHTML(posts ...*Post) error {
+	for _, post := range posts {
+		if post.HTMLRevision == post.Revision {
+			continue
+		}
+
+		html, err := markdown.Render(post.Content)
+		if err != nil {
+			return fmt.Errorf("cannot render post with id = %d: %w", post.ID, err)
+		}
+
+		result := db.gormDB.Model(&Post{}).
+			Where("id = ? AND revision = ?", post.ID, post.Revision).
+			Updates(map[string]any{"content_html": html, "html_revision": post.Revision})
+		if result.Error != nil {
+			return fmt.Errorf("cannot cache html of post with id = %d: %w", post.ID, result.Error)
+		}
+
+		post.ContentHTML = html
+		post.HTMLRevision = post.Revision
+	}
+
+	return nil
+}
+
+// refreshMessagesHTML is the same as refreshPostsHTML for messages.
+func (db *Database) refreshMessagesHTML(messages ...*Message) error {
+	for _, message := range messages {
+		if message.HTMLRevision == message.Revision {
+			continue
+		}
+
+		html, err := markdown.Render(message.Content)
+		if err != nil {
+			return fmt.Errorf("cannot render message with id = %d: %w", message.ID, err)
+		}
+
+		result := db.gormDB.Model(&Message{}).
+			Where("id = ? AND revision = ?", message.ID, message.Revision).
+			Updates(map[string]any{"content_html": html, "html_revision": message.Revision})
+		if result.Error != nil {
+			return fmt.Errorf("cannot cache html of message with id = %d: %w", message.ID, result.Error)
+		}
+
+		message.ContentHTML = html
+		message.HTMLRevision = message.Revision
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (db *Database) AddSession(ctx context.Context, session *Session) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Create(session)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot add session of user with id = %d to db: %w",
+			session.UserID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// GetActiveSession returns the session with the token hash if it is not expired or revoked.
+func (db *Database) GetActiveSession(ctx context.Context, tokenHash string) (*Session, error) {
+	db = db.withContext(ctx)
+
+	session := &Session{}
+	result := db.gormDB.
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(session)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get active session: %w", result.Error)
+	}
+
+	return session, nil
+}
+
+func (db *Database) RevokeSession(ctx context.Context, tokenHash string) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&Session{}).
+		Where("token_hash = ? AND revoked_at IS NULL", tokenHash).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("cannot revoke session: %w", result.Error)
+	}
+
+	return nil
+}
+
+func (db *Database) RevokeUserSessions(userID uint) error {
+	result := db.gormDB.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("cannot revoke sessions of user with id = %d: %w", userID, result.Error)
+	}
+
+	return nil
+}
+
+func (db *Database) AddPasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Create(token)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot add password reset token of user with id = %d to db: %w",
+			token.UserID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// ResetPassword uses the reset token, sets the new password hash of its user,
+// invalidates the other reset tokens of the user and revokes all their sessions.
+func (db *Database) ResetPassword(
+	ctx context.Context,
+	tokenHash, hashPassword string,
+) (*User, error) {
+	db = db.withContext(ctx)
+
+	var user *User
+
+	err := db.Transaction(func(tx *Database) error {
+		token := &PasswordResetToken{}
+		result := tx.gormDB.Where("token_hash = ?", tokenHash).First(token)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get password reset token: %w", result.Error)
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil || now.After(token.ExpiresAt) {
+			return fmt.Errorf(
+				"cannot use password reset token with id = %d: %w",
+				token.ID,
+				ErrTokenInvalid,
+			)
+		}
+
+		result = tx.gormDB.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot use password reset token with id = %d: %w",
+				token.ID,
+				result.Error,
+			)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf(
+				"cannot use password reset token with id = %d: %w",
+				token.ID,
+				ErrTokenInvalid,
+			)
+		}
+
+		result = tx.gormDB.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot invalidate password reset tokens of user with id = %d: %w",
+				token.UserID,
+				result.Error,
+			)
+		}
+
+		result = tx.gormDB.Model(&User{}).
+			Where("id = ? AND removed_at IS NULL", token.UserID).
+			Update("hash_password", hashPassword)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot set password of user with id = %d: %w",
+				token.UserID,
+				result.Error,
+			)
+		}
+
+		if err := tx.RevokeUserSessions(token.UserID); err != nil {
+			return fmt.Errorf("tx.RevokeUserSessions(%d): %w", token.UserID, err)
+		}
+
+		var err error
+		user, err = tx.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			return fmt.Errorf("tx.GetUserByID(%d): %w", token.UserID, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetLoginThrottle returns the failed logins of the key. Keys without failures
+// get an empty throttle.
+func (db *Database) GetLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	db = db.withContext(ctx)
+
+	throttle := &LoginThrottle{}
+	result := db.gormDB.Where("key = ?", key).First(throttle)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return &LoginThrottle{Key: key}, nil
+	} else if result.Error != nil {
+		return nil, fmt.Errorf("cannot get login throttle by key = %q: %w", key, result.Error)
+	}
+
+	return throttle, nil
+}
+
+// AddLoginFailure counts a failed login of the key. Failures older than the window
+// are forgotten, so the count starts over.
+func (db *Database) AddLoginFailure(
+	ctx context.Context,
+	key string,
+	at time.Time,
+	window time.Duration,
+) (*LoginThrottle, error) {
+	db = db.withContext(ctx)
+
+	throttle := &LoginThrottle{
+		Key:           key,
+		Failures:      1,
+		LastFailureAt: at,
+	}
+	result := db.gormDB.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"failures": gorm.Expr(
+					"CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END",
+					at.Add(-window),
+				),
+				"last_failure_at": at,
+			}),
+		},
+		clause.Returning{},
+	).Create(throttle)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot add login failure of key = %q: %w", key, result.Error)
+	}
+
+	return throttle, nil
+}
+
+func (db *Database) LockLogin(ctx context.Context, key string, until time.Time) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&LoginThrottle{}).Where("key = ?", key).Update("locked_until", until)
+	if result.Error != nil {
+		return fmt.Errorf("cannot lock login of key = %q: %w", key, result.Error)
+	}
+
+	return nil
+}
+
+func (db *Database) ResetLoginThrottle(ctx context.Context, key string) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Where("key = ?", key).Delete(&LoginThrottle{})
+	if result.Error != nil {
+		return fmt.Errorf("cannot reset login throttle of key = %q: %w", key, result.Error)
+	}
+
+	return nil
+}
+
+// UpdateRateLimitBucket locks the bucket of the key and saves the changes made by update.
+// Missing buckets are passed to update with zero RefilledAt.
+func (db *Database) UpdateRateLimitBucket(
+	ctx context.Context,
+	key string,
+	update func(bucket *RateLimitBucket),
+) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&RateLimitBucket{Key: key})
+		if result.Error != nil {
+			return fmt.Errorf("cannot add rate limit bucket with key = %q: %w", key, result.Error)
+		}
+
+		bucket := &RateLimitBucket{}
+		result = tx.gormDB.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).
+			First(bucket)
+		if result.Error != nil {
+			return fmt.Errorf("cannot get rate limit bucket by key = %q: %w", key, result.Error)
+		}
+
+		update(bucket)
+
+		result = tx.gormDB.Save(bucket)
+		if result.Error != nil {
+			return fmt.Errorf("cannot update rate limit bucket with key = %q: %w", key, result.Error)
+		}
+
+		return nil
+	})
+}
+
+// DeleteFullRateLimitBuckets removes the buckets that are full again,
+// they are the same as missing ones.
+func (db *Database) DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Where("full_at <= ?", now).Delete(&RateLimitBucket{})
+	if result.Error != nil {
+		return fmt.Errorf("cannot delete full rate limit buckets: %w", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (db *Database) GetTwoFactor(ctx context.Context, userID uint) (*TwoFactor, error) {
+	db = db.withContext(ctx)
+
+	twoFactor := &TwoFactor{}
+	result := db.gormDB.Where("user_id = ?", userID).First(twoFactor)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get two-factor settings of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return twoFactor, nil
+}
+
+// SetTwoFactorSecret stores a new unconfirmed secret of the user.
+func (db *Database) SetTwoFactorSecret(ctx context.Context, userID uint, secret string) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"secret":         secret,
+			"confirmed_at":   nil,
+			"last_used_step": 0,
+		}),
+	}).Create(&TwoFactor{
+		UserID: userID,
+		Secret: secret,
+	})
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot set two-factor secret of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication of the user
+// and replaces their recovery codes.
+func (db *Database) ConfirmTwoFactor(
+	ctx context.Context,
+	userID uint,
+	step int64,
+	codeHashes []string,
+) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Model(&TwoFactor{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]any{"confirmed_at": time.Now(), "last_used_step": step})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot confirm two-factor authentication of user with id = %d: %w",
+				userID,
+				result.Error,
+			)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf(
+				"cannot confirm two-factor authentication of user with id = %d: %w",
+				userID,
+				gorm.ErrRecordNotFound,
+			)
+		}
+
+		result = tx.gormDB.Where("user_id = ?", userID).Delete(&RecoveryCode{})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot delete recovery codes of user with id = %d: %w",
+				userID,
+				result.Error,
+			)
+		}
+
+		codes := make([]*RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, &RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+
+		result = tx.gormDB.Create(codes)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot add recovery codes of user with id = %d: %w",
+				userID,
+				result.Error,
+			)
+		}
+
+		return nil
+	})
+}
+
+// UseTwoFactorStep records the time step of an accepted code.
+// Codes of the same or an earlier step cannot be used again.
+func (db *Database) UseTwoFactorStep(ctx context.Context, userID uint, step int64) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&TwoFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot use two-factor code of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf(
+			"cannot use two-factor code of user with id = %d: %w",
+			userID,
+			ErrTokenInvalid,
+		)
+	}
+
+	return nil
+}
+
+func (db *Database) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot use recovery code of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf(
+			"cannot use recovery code of user with id = %d: %w",
+			userID,
+			ErrTokenInvalid,
+		)
+	}
+
+	return nil
+}
+
+// GetTwoFactorPolicy returns the policy of the role. Roles without a stored policy
+// do not require two-factor authentication.
+func (db *Database) GetTwoFactorPolicy(ctx context.Context, role string) (*TwoFactorPolicy, error) {
+	db = db.withContext(ctx)
+
+	policy := &TwoFactorPolicy{}
+	result := db.gormDB.Where("role = ?", role).First(policy)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return &TwoFactorPolicy{Role: role}, nil
+	} else if result.Error != nil {
+		return nil, fmt.Errorf("cannot get two-factor policy of role %q: %w", role, result.Error)
+	}
+
+	return policy, nil
+}
+
+func (db *Database) GetAllTwoFactorPolicies(ctx context.Context) ([]*TwoFactorPolicy, error) {
+	db = db.withContext(ctx)
+
+	var policies []*TwoFactorPolicy
+	result := db.gormDB.Order("role").Find(&policies)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get all two-factor policies: %w", result.Error)
+	}
+
+	return policies, nil
+}
+
+func (db *Database) SetTwoFactorPolicy(
+	ctx context.Context,
+	policy *TwoFactorPolicy,
+) (*TwoFactorPolicy, error) {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_at"}),
+	}).Create(policy)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot set two-factor policy %#v: %w", policy, result.Error)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// uniqueViolationCode is the Postgres error code of unique constraint violations.
+const uniqueViolationCode = "23505"
+
+// Transaction runs fn in a transaction and commits it if fn returns nil.
+// All methods of tx run in the transaction, and the notifications they create
+// are published only after the commit. Transactions started on tx are nested
+// in it with savepoints.
+func (db *Database) Transaction(fn func(tx *Database) error) error {
+	if db.pending != nil {
+		return db.gormDB.Transaction(func(gormTx *gorm.DB) error {
+			tx := *db
+			tx.gormDB = gormTx
+
+			return fn(&tx)
+		})
+	}
+
+	var pending []*Notification
+	err := db.gormDB.Transaction(func(gormTx *gorm.DB) error {
+		tx := *db
+		tx.gormDB = gormTx
+		tx.pending = &pending
+
+		return fn(&tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	db.publish(pending...)
+
+	return nil
+}
+
+// translateError maps unique violations to ErrUniqueConstraint
+// and leaves other errors as they are.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%w: %s", ErrUniqueConstraint, pgErr.ConstraintName)
+	}
+
+	return err
+}
+
+// withContext returns a copy of the database that runs its queries with ctx,
+// so they are canceled with the request that made them.
+func (db *Database) withContext(ctx context.Context) *Database {
+	ctxDB := *db
+	ctxDB.gormDB = db.gormDB.WithContext(ctx)
+
+	return &ctxDB
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (db *Database) AddVerificationToken(ctx context.Context, token *VerificationToken) error {
+	db = db.withContext(ctx)
+
+	result := db.gormDB.Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("cannot add verification token %#v to db: %w", token, result.Error)
+	}
+
+	return nil
+}
+
+// CountVerificationTokens returns how many verification tokens were issued
+// to the user since the given time.
+func (db *Database) CountVerificationTokens(
+	ctx context.Context,
+	userID uint,
+	since time.Time,
+) (int64, error) {
+	db = db.withContext(ctx)
+
+	var count int64
+	result := db.gormDB.Model(&VerificationToken{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf(
+			"cannot count verification tokens of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return count, nil
+}
+
+// VerifyUserEmail uses the verification token and marks its user as verified.
+func (db *Database) VerifyUserEmail(ctx context.Context, tokenID uint) (*User, error) {
+	db = db.withContext(ctx)
+
+	var user *User
+
+	err := db.Transaction(func(tx *Database) error {
+		token := &VerificationToken{}
+		result := tx.gormDB.Where("id = ?", tokenID).First(token)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot get verification token by id = %d: %w",
+				tokenID,
+				result.Error,
+			)
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil || now.After(token.ExpiresAt) {
+			return fmt.Errorf(
+				"cannot use verification token with id = %d: %w",
+				tokenID,
+				ErrTokenInvalid,
+			)
+		}
+
+		result = tx.gormDB.Model(&VerificationToken{}).
+			Where("id = ? AND used_at IS NULL", tokenID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot use verification token with id = %d: %w",
+				tokenID,
+				result.Error,
+			)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf(
+				"cannot use verification token with id = %d: %w",
+				tokenID,
+				ErrTokenInvalid,
+			)
+		}
+
+		result = tx.gormDB.Model(&User{}).
+			Where("id = ? AND verified_at IS NULL", token.UserID).
+			Update("verified_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("cannot verify user with id = %d: %w", token.UserID, result.Error)
+		}
+
+		var err error
+		user, err = tx.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			return fmt.Errorf("tx.GetUserByID(%d): %w", token.UserID, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// resetVerification marks the user as unverified and voids the tokens sent
+// to the previous email of the user.
+func (db *Database) resetVerification(userID uint) error {
+	result := db.gormDB.Model(&User{}).Where("id = ?", userID).Update("verified_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("cannot unverify user with id = %d: %w", userID, result.Error)
+	}
+
+	result = db.gormDB.Model(&VerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot void verification tokens of user with id = %d: %w",
+			userID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
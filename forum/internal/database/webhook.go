@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type webhookPayload struct {
+	ID         uint            `json:"id"`
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// userEventData leaves the password hash and the email out of user events.
+type userEventData struct {
+	ID           uint      `json:"id"`
+	Nickname     string    `json:"nickname"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+type deletedEventData struct {
+	ID uint `json:"id"`
+}
+
+type chatMemberEventData struct {
+	ChatID uint `json:"chat_id"`
+	UserID uint `json:"user_id"`
+}
+
+func (db *Database) AddWebhookSubscription(subscription *WebhookSubscription) error {
+	result := db.gormDB.Create(subscription)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot add webhook subscription for %q to db: %w",
+			subscription.URL,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+func (db *Database) GetAllWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	db = db.withContext(ctx)
+
+	var subscriptions []WebhookSubscription
+	result := db.gormDB.Order("id").Find(&subscriptions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get all webhook subscriptions: %w", result.Error)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription deletes the subscription with its delivery log.
+func (db *Database) DeleteWebhookSubscription(ctx context.Context, id uint) error {
+	db = db.withContext(ctx)
+
+	return db.Transaction(func(tx *Database) error {
+		result := tx.gormDB.Where("subscription_id = ?", id).Delete(&WebhookDelivery{})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot delete deliveries of webhook subscription with id = %d: %w",
+				id,
+				result.Error,
+			)
+		}
+
+		result = tx.gormDB.Where("id = ?", id).Delete(&WebhookSubscription{})
+		if result.Error != nil {
+			return fmt.Errorf(
+				"cannot delete webhook subscription with id = %d: %w",
+				id,
+				result.Error,
+			)
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf(
+				"cannot delete webhook subscription with id = %d: %w",
+				id,
+				gorm.ErrRecordNotFound,
+			)
+		}
+
+		return nil
+	})
+}
+
+// QueueWebhookDeliveries queues a delivery of the outbox event for every subscription
+// to it. The event id in the payload lets receivers drop repeated deliveries.
+func (db *Database) QueueWebhookDeliveries(ctx context.Context, event *OutboxEvent) error {
+	db = db.withContext(ctx)
+
+	subscriptions, err := db.GetAllWebhookSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("db.GetAllWebhookSubscriptions(): %w", err)
+	}
+
+	payload, err := json.Marshal(&webhookPayload{
+		ID:         event.ID,
+		Event:      event.Event,
+		OccurredAt: event.CreatedAt,
+		Data:       json.RawMessage(event.Data),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal %q event: %w", event.Event, err)
+	}
+
+	now := time.Now()
+	var deliveries []*WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !slices.Contains(subscription.Events, event.Event) {
+			continue
+		}
+
+		deliveries = append(deliveries, &WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			Event:          event.Event,
+			Payload:        string(payload),
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	result := db.gormDB.Omit("Subscription").Create(deliveries)
+	if result.Error != nil {
+		return fmt.Errorf("cannot add deliveries of %q event: %w", event.Event, result.Error)
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due and
+// postpones them by lease, so other replicas do not send them at the same time.
+func (db *Database) ClaimWebhookDeliveries(
+	now time.Time,
+	lease time.Duration,
+	limit int,
+) ([]*WebhookDelivery, error) {
+	var ids []uint
+	result := db.gormDB.Raw(
+		`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`,
+		now.Add(lease),
+		DeliveryPending,
+		now,
+		limit,
+	).Scan(&ids)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot claim webhook deliveries: %w", result.Error)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var deliveries []*WebhookDelivery
+	result = db.gormDB.Preload("Subscription").Where("id IN ?", ids).Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get claimed webhook deliveries: %w", result.Error)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery saves the result of a delivery attempt.
+func (db *Database) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	result := db.gormDB.Omit("Subscription").Save(delivery)
+	if result.Error != nil {
+		return fmt.Errorf(
+			"cannot update webhook delivery with id = %d: %w",
+			delivery.ID,
+			result.Error,
+		)
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the subscription.
+func (db *Database) GetWebhookDeliveries(
+	ctx context.Context,
+	subscriptionID uint,
+	limit int,
+) ([]WebhookDelivery, error) {
+	db = db.withContext(ctx)
+
+	var deliveries []WebhookDelivery
+	result := db.gormDB.
+		Where("subscription_id = ?", subscriptionID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot get deliveries of webhook subscription with id = %d: %w",
+			subscriptionID,
+			result.Error,
+		)
+	}
+
+	return deliveries, nil
+}
+
+// RedeliverWebhook queues a new delivery with the payload of the delivery,
+// the log of the original one stays as is.
+func (db *Database) RedeliverWebhook(ctx context.Context, id uint) (*WebhookDelivery, error) {
+	db = db.withContext(ctx)
+
+	delivery := &WebhookDelivery{}
+	result := db.gormDB.Where("id = ?", id).First(delivery)
+	if result.Error != nil {
+		return nil, fmt.Errorf("cannot get webhook delivery by id = %d: %w", id, result.Error)
+	}
+
+	now := time.Now()
+	redelivery := &WebhookDelivery{
+		SubscriptionID: delivery.SubscriptionID,
+		Event:          delivery.Event,
+		Payload:        delivery.Payload,
+		Status:         DeliveryPending,
+		NextAttemptAt:  &now,
+	}
+
+	result = db.gormDB.Omit("Subscription").Create(redelivery)
+	if result.Error != nil {
+		return nil, fmt.Errorf(
+			"cannot add redelivery of webhook delivery with id = %d: %w",
+			id,
+			result.Error,
+		)
+	}
+
+	return redelivery, nil
+}
@@ -0,0 +1,44 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mentionRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_]+)`)
+	hashtagRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+)
+
+// Mentions returns the unique nicknames mentioned as @nickname in text
+// in order of first appearance.
+func Mentions(text string) []string {
+	return unique(mentionRegexp.FindAllStringSubmatch(text, -1), false)
+}
+
+// Hashtags returns the unique lowercased tags written as #tag in text
+// in order of first appearance.
+func Hashtags(text string) []string {
+	return unique(hashtagRegexp.FindAllStringSubmatch(text, -1), true)
+}
+
+func unique(matches [][]string, lower bool) []string {
+	seen := make(map[string]struct{}, len(matches))
+	result := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		value := match[1]
+		if lower {
+			value = strings.ToLower(value)
+		}
+
+		if _, ok := seen[value]; ok {
+			continue
+		}
+
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+package extract
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "email", text: "mail me at bob@example.com", want: []string{}},
+		{name: "word before at", text: "a@bob", want: []string{}},
+		{name: "double at", text: "@@bob", want: []string{}},
+		{name: "bare at", text: "@", want: []string{}},
+		{
+			name: "punctuation",
+			text: "@bob, @alice! (@carol) @dave_1.",
+			want: []string{"bob", "alice", "carol", "dave_1"},
+		},
+		{name: "duplicates", text: "@bob @alice @bob", want: []string{"bob", "alice"}},
+		{name: "case is kept", text: "@Bob @bob", want: []string{"Bob", "bob"}},
+		{name: "unicode", text: "привет, @иван", want: []string{"иван"}},
+		{name: "hashtag after mention", text: "@bob#tag", want: []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Mentions(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "word before hash", text: "x#tag", want: []string{}},
+		{name: "double hash", text: "##tag", want: []string{}},
+		{name: "bare hash", text: "#", want: []string{}},
+		{name: "html entity", text: "it&#39;s", want: []string{}},
+		{name: "punctuation", text: "(#go), #rust. #zig!", want: []string{"go", "rust", "zig"}},
+		{name: "duplicates", text: "#go #rust #go", want: []string{"go", "rust"}},
+		{name: "case is folded", text: "#Go #go #GO", want: []string{"go"}},
+		{name: "unicode", text: "#Тег", want: []string{"тег"}},
+		{name: "mention after hashtag", text: "#go@bob", want: []string{"go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hashtags(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Hashtags(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var (
+	ErrShuttingDown      = errors.New("server is shutting down")
+	ErrPendingMigrations = errors.New("migrations are not applied")
+)
+
+type HealthDB interface {
+	Ping(ctx context.Context) error
+	PoolStats() (sql.DBStats, error)
+	CountPendingMigrations(ctx context.Context) (int, error)
+	GetOutboxBacklog(ctx context.Context) (int64, *time.Time, error)
+	CountPendingWebhookDeliveries(ctx context.Context) (int64, error)
+}
+
+// Check is the state of a dependency.
+type Check struct {
+	Status    string         `json:"status"`
+	LatencyMS float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Report is the state of the server with every dependency. The server is up
+// only if all of them are.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]*Check `json:"checks"`
+}
+
+// Checker answers liveness, readiness and detailed health checks.
+type Checker struct {
+	db           HealthDB
+	token        string
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewChecker returns the checker that gives up on a dependency after the timeout.
+// When token is not empty, the detailed report requires it.
+func NewChecker(db HealthDB, token string, timeout time.Duration) *Checker {
+	return &Checker{
+		db:      db,
+		token:   token,
+		timeout: timeout,
+	}
+}
+
+// ShutDown makes the server not ready, so load balancers stop sending requests to it.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Authorized reports whether the token lets the caller see the detailed report.
+func (c *Checker) Authorized(token string) bool {
+	return c.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) == 1
+}
+
+// Ready returns an error if the server cannot serve requests.
+func (c *Checker) Ready(ctx context.Context) error {
+	if c.shuttingDown.Load() {
+		return ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := c.db.Ping(ctx); err != nil {
+		return fmt.Errorf("c.db.Ping(): %w", err)
+	}
+
+	pending, err := c.db.CountPendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("c.db.CountPendingMigrations(): %w", err)
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%d pending: %w", pending, ErrPendingMigrations)
+	}
+
+	return nil
+}
+
+// Report checks all dependencies concurrently.
+func (c *Checker) Report(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) (map[string]any, error){
+		"postgres":   c.checkPostgres,
+		"migrations": c.checkMigrations,
+		"outbox":     c.checkOutbox,
+		"webhooks":   c.checkWebhooks,
+	}
+
+	report := &Report{
+		Status: StatusUp,
+		Checks: make(map[string]*Check, len(checks)+1),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result := run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	lifecycle := &Check{Status: StatusUp}
+	if c.shuttingDown.Load() {
+		lifecycle = &Check{Status: StatusDown, Error: ErrShuttingDown.Error()}
+		report.Status = StatusDown
+	}
+	report.Checks["lifecycle"] = lifecycle
+
+	return report
+}
+
+// run runs the check. Its queries are canceled when ctx is done.
+func run(ctx context.Context, check func(ctx context.Context) (map[string]any, error)) *Check {
+	start := time.Now()
+	details, err := check(ctx)
+
+	checked := &Check{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+
+	if err != nil {
+		checked.Status = StatusDown
+		checked.Error = err.Error()
+	}
+
+	return checked
+}
+
+func (c *Checker) checkPostgres(ctx context.Context) (map[string]any, error) {
+	stats, err := c.db.PoolStats()
+	if err != nil {
+		return nil, fmt.Errorf("c.db.PoolStats(): %w", err)
+	}
+
+	details := map[string]any{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"max_open":         stats.MaxOpenConnections,
+		"wait_count":       stats.WaitCount,
+		"wait_duration":    stats.WaitDuration.String(),
+	}
+
+	if err := c.db.Ping(ctx); err != nil {
+		return details, fmt.Errorf("c.db.Ping(): %w", err)
+	}
+
+	return details, nil
+}
+
+func (c *Checker) checkMigrations(ctx context.Context) (map[string]any, error) {
+	pending, err := c.db.CountPendingMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("c.db.CountPendingMigrations(): %w", err)
+	}
+
+	details := map[string]any{"pending": pending}
+	if pending > 0 {
+		return details, ErrPendingMigrations
+	}
+
+	return details, nil
+}
+
+// checkOutbox reports the unpublished events. A backlog is not an error,
+// the relay retries failed events.
+func (c *Checker) checkOutbox(ctx context.Context) (map[string]any, error) {
+	count, oldest, err := c.db.GetOutboxBacklog(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("c.db.GetOutboxBacklog(): %w", err)
+	}
+
+	details := map[string]any{"unpublished": count}
+	if oldest != nil {
+		details["oldest_age"] = time.Since(*oldest).Round(time.Second).String()
+	}
+
+	return details, nil
+}
+
+func (c *Checker) checkWebhooks(ctx context.Context) (map[string]any, error) {
+	pending, err := c.db.CountPendingWebhookDeliveries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("c.db.CountPendingWebhookDeliveries(): %w", err)
+	}
+
+	return map[string]any{"pending_deliveries": pending}, nil
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.@-]`)
+
+// FileMailer writes every email as an .eml file into a directory instead of sending it.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot make mail directory %q: %w", dir, err)
+	}
+
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, message *Message) error {
+	data, err := message.bytes(m.from)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	name := fmt.Sprintf(
+		"%d-%s.eml",
+		time.Now().UnixNano(),
+		unsafeFileChars.ReplaceAllString(message.To, "_"),
+	)
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("cannot write message to %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// MemoryMailer keeps sent emails in memory, so tests can inspect them.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(_ context.Context, message *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+
+	return nil
+}
+
+// Messages returns the emails sent so far.
+func (m *MemoryMailer) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*Message(nil), m.messages...)
+}
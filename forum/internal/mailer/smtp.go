@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type SMTPMailer struct {
+	address  string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(address, username, password, from string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", address, err)
+	}
+
+	return &SMTPMailer{
+		address:  address,
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message *Message) error {
+	data, err := message.bytes(m.from)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.address)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", m.address, err)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot start smtp session with %q: %w", m.address, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("cannot start tls: %w", err)
+		}
+	}
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("cannot authenticate as %q: %w", m.username, err)
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("cannot set sender %q: %w", m.from, err)
+	}
+
+	if err := client.Rcpt(message.To); err != nil {
+		return fmt.Errorf("cannot set recipient %q: %w", message.To, err)
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("cannot start message data: %w", err)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("cannot write message data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("cannot finish message data: %w", err)
+	}
+
+	return client.Quit()
+}
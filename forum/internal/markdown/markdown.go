@@ -0,0 +1,46 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+var (
+	converter = goldmark.New()
+	policy    = newPolicy()
+)
+
+// newPolicy allows only the elements produced by the CommonMark core and forces
+// rel="nofollow" on every link. Scripts, styles and event attributes are dropped.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"p", "br", "hr", "em", "strong", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+
+	p.AllowStandardURLs()
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.RequireNoFollowOnLinks(true)
+
+	return p
+}
+
+// Render converts CommonMark source into sanitized HTML.
+// Raw HTML in the source is never passed through.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		return "", fmt.Errorf("cannot convert markdown: %w", err)
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,71 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "script tag", source: "<script>alert(1)</script>", want: "\n"},
+		{name: "inline script tag", source: "hi <script>alert(1)</script>", want: "<p>hi alert(1)</p>\n"},
+		{name: "style tag", source: "<style>body{}</style>", want: "\n"},
+		{name: "iframe", source: "<iframe src=https://example.com></iframe>", want: "\n"},
+		{name: "javascript link", source: "[x](javascript:alert(1))", want: "<p>x</p>\n"},
+		{name: "mixed case javascript link", source: "[x](JaVaScRiPt:alert(1))", want: "<p>x</p>\n"},
+		{name: "javascript image", source: "![x](javascript:alert(1))", want: "<p><img alt=\"x\"></p>\n"},
+		{name: "data link", source: "[x](data:text/html;base64,PHNjcmlwdD4=)", want: "<p>x</p>\n"},
+		{
+			name:   "html javascript link",
+			source: `<a href="javascript:alert(1)">x</a>`,
+			want:   "<p>x</p>\n",
+		},
+		{
+			name:   "javascript autolink",
+			source: "<javascript:alert(1)>",
+			want:   "<p>javascript:alert(1)</p>\n",
+		},
+		{name: "onerror attribute", source: "<img src=x onerror=alert(1)>", want: "\n"},
+		{name: "onclick attribute", source: `<p onclick="alert(1)">x</p>`, want: "\n"},
+		{
+			name:   "link",
+			source: `[x](https://example.com "t")`,
+			want:   "<p><a href=\"https://example.com\" title=\"t\" rel=\"nofollow\">x</a></p>\n",
+		},
+		{
+			name:   "autolink",
+			source: "<https://example.com>",
+			want: "<p><a href=\"https://example.com\" rel=\"nofollow\">" +
+				"https://example.com</a></p>\n",
+		},
+		{
+			name:   "emphasis and code",
+			source: "**b** _i_ `c`",
+			want:   "<p><strong>b</strong> <em>i</em> <code>c</code></p>\n",
+		},
+		{
+			name:   "code block language",
+			source: "```go\nx\n```",
+			want:   "<pre><code class=\"language-go\">x\n</code></pre>\n",
+		},
+		{
+			name:   "ordered list start",
+			source: "3. a\n4. b",
+			want:   "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tt.source, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID       uint
+	Nickname string
+}
+
+// sampleCount returns how many observations the series of the histogram got.
+func sampleCount(t *testing.T, histogram *prometheus.HistogramVec, labels ...string) uint64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := histogram.WithLabelValues(labels...).(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("cannot write histogram %v: %s", labels, err)
+	}
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestObserveRequest(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.ObserveRequest(http.MethodGet, "/post/:id", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/post/:id", http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/post/:id", http.StatusNotFound, time.Millisecond)
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/post/:id", "200")); got != 2 {
+		t.Errorf("got %v requests with status 200, want 2", got)
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/post/:id", "404")); got != 1 {
+		t.Errorf("got %v requests with status 404, want 1", got)
+	}
+
+	if got := sampleCount(t, m.requestDuration, "GET", "/post/:id", "200"); got != 2 {
+		t.Errorf("got %d request durations with status 200, want 2", got)
+	}
+}
+
+func TestBusinessCounters(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := New(registry)
+
+	m.UserRegistered()
+	m.PostCreated()
+	m.PostCreated()
+	m.MessageSent()
+	m.StreamOpened()
+	m.StreamOpened()
+	m.StreamClosed()
+
+	expected := `
+# HELP forum_messages_sent_total Messages sent by users and incoming webhooks.
+# TYPE forum_messages_sent_total counter
+forum_messages_sent_total 1
+# HELP forum_notification_streams_active Open notification streams.
+# TYPE forum_notification_streams_active gauge
+forum_notification_streams_active 1
+# HELP forum_posts_created_total Created posts.
+# TYPE forum_posts_created_total counter
+forum_posts_created_total 2
+# HELP forum_users_registered_total Registered users.
+# TYPE forum_users_registered_total counter
+forum_users_registered_total 1
+`
+	err := testutil.GatherAndCompare(
+		registry,
+		strings.NewReader(expected),
+		"forum_messages_sent_total",
+		"forum_notification_streams_active",
+		"forum_posts_created_total",
+		"forum_users_registered_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	m.PostCreated()
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.Contains(recorder.Body.String(), "forum_posts_created_total 1") {
+		t.Errorf("got metrics %q, want forum_posts_created_total 1", recorder.Body.String())
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	// Dry runs build the statements and run the callbacks without a database.
+	db, err := gorm.Open(
+		postgres.New(postgres.Config{DSN: "host=localhost"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true},
+	)
+	if err != nil {
+		t.Fatalf("cannot open dry run db: %s", err)
+	}
+
+	if err := db.Use(m.GormPlugin()); err != nil {
+		t.Fatalf("cannot use metrics plugin: %s", err)
+	}
+
+	db.Create(&user{Nickname: "alice"})
+	db.Where("id = ?", 1).Find(&[]user{})
+	db.Where("id = ?", 1).Find(&[]user{})
+	db.Model(&user{}).Where("id = ?", 1).Update("nickname", "bob")
+	db.Delete(&user{}, 1)
+
+	for operation, want := range map[string]uint64{
+		"create": 1,
+		"query":  2,
+		"update": 1,
+		"delete": 1,
+	} {
+		if got := sampleCount(t, m.queryDuration, operation, "users"); got != want {
+			t.Errorf("got %d %s queries of users, want %d", got, operation, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"github.com/LLIEPJIOK/forum/internal/mailer"
+)
+
+const (
+	immediateInterval   = 30 * time.Second
+	digestCheckInterval = time.Hour
+	digestPeriod        = 24 * time.Hour
+)
+
+type EmailDB interface {
+	GetUserByID(ctx context.Context, id uint) (*database.User, error)
+	GetNotificationPreference(
+		ctx context.Context,
+		userID uint,
+	) (*database.NotificationPreference, error)
+	GetPendingImmediateNotifications() ([]*database.Notification, error)
+	MarkNotificationEmailed(id uint, emailedAt time.Time) error
+	GetDailyDigestUsers(sentBefore time.Time) ([]*database.User, error)
+	GetDigestNotifications(userID uint, since time.Time) ([]*database.Notification, error)
+	MarkDigestSent(userID uint, sentAt time.Time) error
+}
+
+// EmailSender emails notifications to users who want them immediately
+// and batches the rest into daily digests.
+type EmailSender struct {
+	db     EmailDB
+	mailer mailer.Mailer
+	logger *slog.Logger
+}
+
+func NewEmailSender(db EmailDB, mailer mailer.Mailer, logger *slog.Logger) *EmailSender {
+	return &EmailSender{
+		db:     db,
+		mailer: mailer,
+		logger: logger,
+	}
+}
+
+// Run sends pending emails until ctx is done.
+func (s *EmailSender) Run(ctx context.Context) {
+	immediateTicker := time.NewTicker(immediateInterval)
+	defer immediateTicker.Stop()
+
+	digestTicker := time.NewTicker(digestCheckInterval)
+	defer digestTicker.Stop()
+
+	s.sendDigests(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-immediateTicker.C:
+			s.sendImmediate(ctx)
+		case <-digestTicker.C:
+			s.sendDigests(ctx)
+		}
+	}
+}
+
+func (s *EmailSender) sendImmediate(ctx context.Context) {
+	notifications, err := s.db.GetPendingImmediateNotifications()
+	if err != nil {
+		s.logger.Error(
+			fmt.Sprintf("s.db.GetPendingImmediateNotifications(): %s", err),
+			"method",
+			"sender.sendImmediate",
+		)
+		return
+	}
+
+	for _, notification := range notifications {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := s.sendNotification(ctx, notification); err != nil {
+			s.logger.Error(
+				fmt.Sprintf("s.sendNotification(%d): %s", notification.ID, err),
+				"method",
+				"sender.sendImmediate",
+			)
+		}
+	}
+}
+
+func (s *EmailSender) sendNotification(
+	ctx context.Context,
+	notification *database.Notification,
+) error {
+	user, err := s.db.GetUserByID(ctx, notification.UserID)
+	if err != nil {
+		return fmt.Errorf("s.db.GetUserByID(%d): %w", notification.UserID, err)
+	}
+
+	if user.Email == nil {
+		return nil
+	}
+
+	message, err := mailer.NewMessage(
+		*user.Email,
+		"New notification",
+		"notification",
+		map[string]any{
+			"Nickname": user.Nickname,
+			"Text":     s.describe(ctx, notification),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("mailer.NewMessage(): %w", err)
+	}
+
+	if err := s.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("s.mailer.Send(): %w", err)
+	}
+
+	if err := s.db.MarkNotificationEmailed(notification.ID, time.Now()); err != nil {
+		return fmt.Errorf("s.db.MarkNotificationEmailed(%d): %w", notification.ID, err)
+	}
+
+	return nil
+}
+
+func (s *EmailSender) sendDigests(ctx context.Context) {
+	now := time.Now()
+
+	users, err := s.db.GetDailyDigestUsers(now.Add(-digestPeriod))
+	if err != nil {
+		s.logger.Error(
+			fmt.Sprintf("s.db.GetDailyDigestUsers(): %s", err),
+			"method",
+			"sender.sendDigests",
+		)
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := s.sendDigest(ctx, user, now); err != nil {
+			s.logger.Error(
+				fmt.Sprintf("s.sendDigest(%d): %s", user.ID, err),
+				"method",
+				"sender.sendDigests",
+			)
+		}
+	}
+}
+
+func (s *EmailSender) sendDigest(ctx context.Context, user *database.User, now time.Time) error {
+	preference, err := s.db.GetNotificationPreference(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("s.db.GetNotificationPreference(%d): %w", user.ID, err)
+	}
+
+	var since time.Time
+	if preference.LastDigestAt != nil {
+		since = *preference.LastDigestAt
+	}
+
+	notifications, err := s.db.GetDigestNotifications(user.ID, since)
+	if err != nil {
+		return fmt.Errorf("s.db.GetDigestNotifications(%d): %w", user.ID, err)
+	}
+
+	if len(notifications) > 0 {
+		items := make([]string, 0, len(notifications))
+		for _, notification := range notifications {
+			items = append(items, s.describe(ctx, notification))
+		}
+
+		message, err := mailer.NewMessage(
+			*user.Email,
+			"Your daily digest",
+			"digest",
+			map[string]any{
+				"Nickname": user.Nickname,
+				"Items":    items,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("mailer.NewMessage(): %w", err)
+		}
+
+		if err := s.mailer.Send(ctx, message); err != nil {
+			return fmt.Errorf("s.mailer.Send(): %w", err)
+		}
+	}
+
+	if err := s.db.MarkDigestSent(user.ID, now); err != nil {
+		return fmt.Errorf("s.db.MarkDigestSent(%d): %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// describe returns a human-readable line about the notification.
+func (s *EmailSender) describe(ctx context.Context, notification *database.Notification) string {
+	actor := "Someone"
+	if notification.ActorID != nil {
+		if user, err := s.db.GetUserByID(ctx, *notification.ActorID); err == nil {
+			actor = user.Nickname
+		}
+	}
+
+	switch {
+	case notification.Type == database.NotificationMention && notification.PostID != nil:
+		return fmt.Sprintf("%s mentioned you in post #%d", actor, *notification.PostID)
+	case notification.Type == database.NotificationMention && notification.ChatID != nil:
+		return fmt.Sprintf("%s mentioned you in chat #%d", actor, *notification.ChatID)
+	case notification.Type == database.NotificationChatMemberAdded && notification.ChatID != nil:
+		return fmt.Sprintf("You were added to chat #%d", *notification.ChatID)
+	default:
+		return fmt.Sprintf("You have a new %q notification", notification.Type)
+	}
+}
@@ -0,0 +1,92 @@
+package notification
+
+import (
+	"sync"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+const subscriberBufferSize = 16
+
+// Hub fans out new notifications to the streams of their recipients.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan *database.Notification]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[uint]map[chan *database.Notification]struct{}),
+	}
+}
+
+// Publish sends the notification to every stream of its recipient.
+// Slow streams that cannot keep up miss the notification instead of blocking the writer.
+func (h *Hub) Publish(notification *database.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	for ch := range h.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel with new notifications of the user and
+// a function that must be called to stop receiving them.
+// The channel is closed when the hub is closed.
+func (h *Hub) Subscribe(userID uint) (<-chan *database.Notification, func()) {
+	ch := make(chan *database.Notification, subscriberBufferSize)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+
+		return ch, func() {}
+	}
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan *database.Notification]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Close closes the channels of all subscribers, so their streams end
+// and the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.closed = true
+	for _, channels := range h.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+	}
+	h.subscribers = make(map[uint]map[chan *database.Notification]struct{})
+}
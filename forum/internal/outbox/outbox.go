@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+const (
+	relayInterval   = time.Second
+	relayBatchSize  = 100
+	cleanupInterval = time.Hour
+	retention       = 24 * time.Hour
+)
+
+// Sink publishes outbox events. Events may be published more than once,
+// so sinks must tolerate repeats.
+type Sink interface {
+	Publish(ctx context.Context, event *database.OutboxEvent) error
+}
+
+type OutboxDB interface {
+	RelayOutbox(limit int, publish func(event *database.OutboxEvent) error) (int, error)
+	DeletePublishedOutboxEvents(before time.Time) error
+}
+
+// Relay publishes the events written to the outbox to the sink.
+type Relay struct {
+	db     OutboxDB
+	sink   Sink
+	logger *slog.Logger
+}
+
+func NewRelay(db OutboxDB, sink Sink, logger *slog.Logger) *Relay {
+	return &Relay{
+		db:     db,
+		sink:   sink,
+		logger: logger,
+	}
+}
+
+// Run relays events and removes the old published ones until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	relayTicker := time.NewTicker(relayInterval)
+	defer relayTicker.Stop()
+
+	cleanupTicker := time.NewTicker(cleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-relayTicker.C:
+			r.relay(ctx)
+		case <-cleanupTicker.C:
+			r.cleanup()
+		}
+	}
+}
+
+// relay publishes batches until the outbox has no more events ready.
+func (r *Relay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.db.RelayOutbox(relayBatchSize, func(event *database.OutboxEvent) error {
+			if err := r.sink.Publish(ctx, event); err != nil {
+				r.logger.Error(
+					fmt.Sprintf("r.sink.Publish(%d): %s", event.ID, err),
+					"method",
+					"outbox.relay",
+				)
+
+				return err
+			}
+
+			return nil
+		})
+		if err != nil {
+			r.logger.Error(fmt.Sprintf("r.db.RelayOutbox(): %s", err), "method", "outbox.relay")
+			return
+		}
+
+		if published < relayBatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) cleanup() {
+	if err := r.db.DeletePublishedOutboxEvents(time.Now().Add(-retention)); err != nil {
+		r.logger.Error(
+			fmt.Sprintf("r.db.DeletePublishedOutboxEvents(): %s", err),
+			"method",
+			"outbox.cleanup",
+		)
+	}
+}
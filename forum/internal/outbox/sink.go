@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+type WebhookDB interface {
+	QueueWebhookDeliveries(ctx context.Context, event *database.OutboxEvent) error
+}
+
+// WebhookSink queues the events for the webhook subscriptions.
+type WebhookSink struct {
+	db WebhookDB
+}
+
+func NewWebhookSink(db WebhookDB) *WebhookSink {
+	return &WebhookSink{
+		db: db,
+	}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *database.OutboxEvent) error {
+	if err := s.db.QueueWebhookDeliveries(ctx, event); err != nil {
+		return fmt.Errorf("s.db.QueueWebhookDeliveries(%d): %w", event.ID, err)
+	}
+
+	return nil
+}
+
+// LogSink writes the events to the log, it is useful when nothing consumes them yet.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{
+		logger: logger,
+	}
+}
+
+func (s *LogSink) Publish(_ context.Context, event *database.OutboxEvent) error {
+	s.logger.Info(
+		"event is published",
+		"event", event.Event,
+		"event_id", event.ID,
+		"aggregate_type", event.AggregateType,
+		"aggregate_id", event.AggregateID,
+		"data", event.Data,
+		"method", "outbox.LogSink.Publish",
+	)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Burst: 1, Interval: time.Second}
+	now := time.Unix(1_700_000_000, 0)
+
+	first, _ := store.Take(ctx, "a", limit, now)
+	second, _ := store.Take(ctx, "a", limit, now)
+	other, _ := store.Take(ctx, "b", limit, now)
+	refilled, _ := store.Take(ctx, "a", limit, now.Add(time.Second))
+
+	for _, tt := range []struct {
+		name    string
+		result  *Result
+		allowed bool
+	}{
+		{name: "first take", result: first, allowed: true},
+		{name: "second take", result: second, allowed: false},
+		{name: "other key", result: other, allowed: true},
+		{name: "refilled", result: refilled, allowed: true},
+	} {
+		if tt.result.Allowed != tt.allowed {
+			t.Errorf("%s: got allowed %v, want %v", tt.name, tt.result.Allowed, tt.allowed)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Burst: 1, Interval: time.Second}
+	now := time.Unix(1_700_000_000, 0)
+
+	store.Take(ctx, "busy", limit, now.Add(time.Minute))
+	for i := 1; i < sweepEvery-1; i++ {
+		store.Take(ctx, fmt.Sprintf("idle %d", i), limit, now)
+	}
+
+	// The next take sweeps the idle buckets, which are full again a minute later.
+	store.Take(ctx, "new", limit, now.Add(time.Minute))
+
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Errorf("sweep removed a bucket that is not full")
+	}
+
+	if len(store.buckets) != 2 {
+		t.Errorf("got %d buckets after the sweep, want 2", len(store.buckets))
+	}
+}
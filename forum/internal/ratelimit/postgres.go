@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+type BucketDB interface {
+	UpdateRateLimitBucket(
+		ctx context.Context,
+		key string,
+		update func(bucket *database.RateLimitBucket),
+	) error
+	DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error
+}
+
+// PostgresStore keeps the buckets in the database, so all replicas share them.
+type PostgresStore struct {
+	db    BucketDB
+	takes atomic.Int64
+}
+
+func NewPostgresStore(db BucketDB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+func (s *PostgresStore) Take(
+	ctx context.Context,
+	key string,
+	limit Limit,
+	now time.Time,
+) (*Result, error) {
+	if s.takes.Add(1)%sweepEvery == 0 {
+		if err := s.db.DeleteFullRateLimitBuckets(ctx, now); err != nil {
+			return nil, fmt.Errorf("s.db.DeleteFullRateLimitBuckets(): %w", err)
+		}
+	}
+
+	var result *Result
+
+	err := s.db.UpdateRateLimitBucket(ctx, key, func(bucket *database.RateLimitBucket) {
+		bucket.Tokens, result = take(bucket.Tokens, bucket.RefilledAt, limit, now)
+		bucket.RefilledAt = now
+		bucket.FullAt = now.Add(result.ResetAfter)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s.db.UpdateRateLimitBucket(%q): %w", key, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket that holds up to Burst requests
+// and gets one more request every Interval.
+type Limit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// Rules are the limits of the routes. Routes are written as "METHOD /full/path"
+// and the ones without their own limit share the Default one. Auth limits
+// the lookups of bearer tokens of a client IP.
+type Rules struct {
+	Default Limit
+	Routes  map[string]Limit
+	Auth    Limit
+}
+
+// Result describes the bucket after the request was counted.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets. Take counts a request to the bucket of the key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error)
+}
+
+type Limiter struct {
+	store Store
+	rules Rules
+}
+
+func New(store Store, rules Rules) *Limiter {
+	return &Limiter{
+		store: store,
+		rules: rules,
+	}
+}
+
+// Allow counts a request of the client to the route.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (*Result, error) {
+	limit, ok := l.rules.Routes[route]
+	if !ok {
+		route = "*"
+		limit = l.rules.Default
+	}
+
+	return l.take(ctx, route, client, limit)
+}
+
+// AllowAuth counts a lookup of a bearer token sent by the client.
+// It is checked before the lookup, so invalid tokens are limited too.
+func (l *Limiter) AllowAuth(ctx context.Context, client string) (*Result, error) {
+	return l.take(ctx, "auth", client, l.rules.Auth)
+}
+
+func (l *Limiter) take(ctx context.Context, route, client string, limit Limit) (*Result, error) {
+	if limit.Burst <= 0 || limit.Interval <= 0 {
+		return &Result{Allowed: true}, nil
+	}
+
+	result, err := l.store.Take(ctx, fmt.Sprintf("%s|%s", route, client), limit, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("l.store.Take(%q): %w", route, err)
+	}
+
+	return result, nil
+}
+
+// take refills the bucket since refilledAt and tries to take a token from it.
+// Buckets that were never refilled are full.
+func take(
+	tokens float64,
+	refilledAt time.Time,
+	limit Limit,
+	now time.Time,
+) (float64, *Result) {
+	burst := float64(limit.Burst)
+
+	if refilledAt.IsZero() {
+		tokens = burst
+	} else if elapsed := now.Sub(refilledAt); elapsed > 0 {
+		tokens = math.Min(burst, tokens+float64(elapsed)/float64(limit.Interval))
+	}
+
+	result := &Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(limit.Interval))
+	}
+
+	result.Remaining = int(tokens)
+	result.ResetAfter = time.Duration((burst - tokens) * float64(limit.Interval))
+
+	return tokens, result
+}
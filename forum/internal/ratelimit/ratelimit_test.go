@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Burst: 3, Interval: time.Second}
+	start := time.Unix(1_700_000_000, 0)
+
+	// The steps take from one bucket in order.
+	steps := []struct {
+		name       string
+		after      time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}{
+		{name: "full bucket", allowed: true, remaining: 2, resetAfter: time.Second},
+		{name: "burst", allowed: true, remaining: 1, resetAfter: 2 * time.Second},
+		{name: "last token", allowed: true, remaining: 0, resetAfter: 3 * time.Second},
+		{name: "empty bucket", retryAfter: time.Second, resetAfter: 3 * time.Second},
+		{
+			name:       "half refilled",
+			after:      500 * time.Millisecond,
+			retryAfter: 500 * time.Millisecond,
+			resetAfter: 2500 * time.Millisecond,
+		},
+		{name: "refilled", after: time.Second, allowed: true, resetAfter: 3 * time.Second},
+		{
+			name:       "refill stops at burst",
+			after:      time.Minute,
+			allowed:    true,
+			remaining:  2,
+			resetAfter: time.Second,
+		},
+		{
+			name:       "clock going back",
+			after:      time.Minute - time.Second,
+			allowed:    true,
+			remaining:  1,
+			resetAfter: 2 * time.Second,
+		},
+	}
+
+	var (
+		tokens     float64
+		refilledAt time.Time
+	)
+
+	for _, step := range steps {
+		now := start.Add(step.after)
+
+		var result *Result
+		tokens, result = take(tokens, refilledAt, limit, now)
+		refilledAt = now
+
+		want := Result{
+			Allowed:    step.allowed,
+			Limit:      limit.Burst,
+			Remaining:  step.remaining,
+			RetryAfter: step.retryAfter,
+			ResetAfter: step.resetAfter,
+		}
+		if *result != want {
+			t.Errorf("%s: got %+v, want %+v", step.name, *result, want)
+		}
+	}
+}
+
+// failingStore fails every take.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (*Result, error) {
+	return nil, errors.New("store is down")
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := New(NewMemoryStore(), Rules{
+		Default: Limit{Burst: 2, Interval: time.Hour},
+		Routes: map[string]Limit{
+			"POST /api/v1/posts": {Burst: 1, Interval: time.Hour},
+			"GET /api/v1/posts":  {},
+		},
+		Auth: Limit{Burst: 1, Interval: time.Hour},
+	})
+
+	tests := []struct {
+		name   string
+		allow  func() (*Result, error)
+		wanted bool
+	}{
+		{name: "own limit", allow: allow(limiter, "POST /api/v1/posts", "a"), wanted: true},
+		{name: "own limit spent", allow: allow(limiter, "POST /api/v1/posts", "a")},
+		{name: "other client", allow: allow(limiter, "POST /api/v1/posts", "b"), wanted: true},
+		{name: "default limit", allow: allow(limiter, "GET /api/v1/users", "a"), wanted: true},
+		{name: "default limit is shared", allow: allow(limiter, "GET /api/v1/chats", "a"), wanted: true},
+		{name: "default limit spent", allow: allow(limiter, "GET /api/v1/users", "a")},
+		{name: "disabled limit", allow: allow(limiter, "GET /api/v1/posts", "a"), wanted: true},
+		{name: "disabled limit again", allow: allow(limiter, "GET /api/v1/posts", "a"), wanted: true},
+		{name: "auth limit", allow: allowAuth(limiter, "a"), wanted: true},
+		{name: "auth limit spent", allow: allowAuth(limiter, "a")},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.allow()
+		if err != nil {
+			t.Fatalf("%s: got error %v", tt.name, err)
+		}
+
+		if result.Allowed != tt.wanted {
+			t.Errorf("%s: got allowed %v, want %v", tt.name, result.Allowed, tt.wanted)
+		}
+	}
+
+	_, err := New(failingStore{}, Rules{Auth: Limit{Burst: 1, Interval: time.Second}}).
+		AllowAuth(ctx, "a")
+	if err == nil {
+		t.Errorf("got no error from a failing store")
+	}
+}
+
+func allow(limiter *Limiter, route, client string) func() (*Result, error) {
+	return func() (*Result, error) {
+		return limiter.Allow(context.Background(), route, client)
+	}
+}
+
+func allowAuth(limiter *Limiter, client string) func() (*Result, error) {
+	return func() (*Result, error) {
+		return limiter.AllowAuth(context.Background(), client)
+	}
+}
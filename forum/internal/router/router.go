@@ -1,50 +1,187 @@
 package router
 
 import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/auth"
+	"github.com/LLIEPJIOK/forum/internal/config"
 	"github.com/LLIEPJIOK/forum/internal/controller"
+	"github.com/LLIEPJIOK/forum/internal/database"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// QueryTimeouts override the query timeout of the config for some routes.
+// The config can override them too.
+var QueryTimeouts = map[string]time.Duration{
+	// Notification streams stay open until the client leaves.
+	"GET /notifications/stream": 0,
+}
+
+// untracedRoutes are polled all the time, their spans would only be noise.
+var untracedRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+func traced(c *gin.Context) bool {
+	return !untracedRoutes[c.FullPath()]
+}
+
 type Router struct {
 	engine *gin.Engine
 }
 
 func New(ctrl *controller.Controller) *Router {
-	eng := gin.Default()
+	// LogRequest replaces the logger of gin. Recovery comes after it and
+	// the metrics, so requests that panic are logged and counted as 500.
+	eng := gin.New()
+	eng.Use(
+		otelgin.Middleware("forum", otelgin.WithGinFilter(traced)),
+		ctrl.LogRequest,
+		ctrl.ObserveRequest,
+		gin.Recovery(),
+	)
+
+	// Probes and metrics are registered before the rate limiter, so they are never throttled.
+	eng.GET("/healthz", ctrl.Healthz)
+	eng.GET("/readyz", ctrl.Readyz)
+	eng.GET("/health", ctrl.Health)
+	eng.GET("/metrics", ctrl.Metrics)
+
+	eng.Use(ctrl.Timeout, ctrl.RateLimit, ctrl.Trace)
+
+	usersRead := ctrl.RequireScope(auth.ScopeUsersRead)
+	usersWrite := ctrl.RequireScope(auth.ScopeUsersWrite)
+	postsRead := ctrl.RequireScope(auth.ScopePostsRead)
+	postsWrite := ctrl.RequireScope(auth.ScopePostsWrite)
+	chatsRead := ctrl.RequireScope(auth.ScopeChatsRead)
+	chatsWrite := ctrl.RequireScope(auth.ScopeChatsWrite)
+	notificationsRead := ctrl.RequireScope(auth.ScopeNotificationsRead)
+	notificationsWrite := ctrl.RequireScope(auth.ScopeNotificationsWrite)
 
 	user := eng.Group("/user")
-	user.POST("", ctrl.AddUser)
-	user.GET(":id", ctrl.GetUser)
-	user.GET("/list/", ctrl.GetAllUsers)
-	user.PUT(":id", ctrl.UpdateUser)
-	user.DELETE(":id", ctrl.DeleteUser)
+	user.POST("", usersWrite, ctrl.AddUser)
+	user.GET(":id", usersRead, ctrl.GetUser)
+	user.GET("/list/", usersRead, ctrl.GetAllUsers)
+	user.PUT(":id", ctrl.RequireUser, usersWrite, ctrl.UpdateUser)
+	user.DELETE(":id", ctrl.RequireUser, usersWrite, ctrl.DeleteUser)
+	user.GET(":id/mentions", postsRead, ctrl.GetUserMentions)
+	user.GET(
+		":id/notification-preference",
+		ctrl.RequireUser,
+		notificationsRead,
+		ctrl.GetNotificationPreference,
+	)
+	user.PUT(
+		":id/notification-preference",
+		ctrl.RequireUser,
+		notificationsWrite,
+		ctrl.UpdateNotificationPreference,
+	)
 
 	post := eng.Group("/post")
-	post.POST("", ctrl.AddPost)
-	post.GET(":id", ctrl.GetPost)
-	post.GET("/list/", ctrl.GetAllPosts)
-	post.PUT(":id", ctrl.UpdatePost)
-	post.DELETE(":id", ctrl.DeletePost)
+	post.POST("", ctrl.RequireUser, postsWrite, ctrl.AddPost)
+	post.GET(":id", postsRead, ctrl.GetPost)
+	post.GET("/list/", postsRead, ctrl.GetAllPosts)
+	post.PUT(":id", ctrl.RequireUser, postsWrite, ctrl.UpdatePost)
+	post.DELETE(":id", ctrl.RequireUser, postsWrite, ctrl.DeletePost)
 
 	message := eng.Group("/message")
-	message.POST("", ctrl.AddMessage)
-	message.GET(":id", ctrl.GetMessage)
-	message.GET("/list/", ctrl.GetAllMessages)
-	message.PUT(":id", ctrl.UpdateMessage)
-	message.DELETE(":id", ctrl.DeleteMessage)
+	message.POST("", ctrl.RequireUser, chatsWrite, ctrl.AddMessage)
+	message.GET(":id", chatsRead, ctrl.GetMessage)
+	message.GET("/list/", chatsRead, ctrl.GetAllMessages)
+	message.PUT(":id", ctrl.RequireUser, chatsWrite, ctrl.UpdateMessage)
+	message.DELETE(":id", ctrl.RequireUser, chatsWrite, ctrl.DeleteMessage)
 
 	chat := eng.Group("/chat")
-	chat.POST("", ctrl.AddChat)
-	chat.GET(":id", ctrl.GetChat)
-	chat.GET("/list/", ctrl.GetAllChats)
-	chat.PUT(":id", ctrl.UpdateChat)
-	chat.DELETE(":id", ctrl.DeleteChat)
+	chat.POST("", ctrl.RequireUser, chatsWrite, ctrl.AddChat)
+	chat.GET(":id", chatsRead, ctrl.GetChat)
+	chat.GET("/list/", chatsRead, ctrl.GetAllChats)
+	chat.PUT(":id", ctrl.RequireUser, chatsWrite, ctrl.UpdateChat)
+	chat.DELETE(":id", ctrl.RequireUser, chatsWrite, ctrl.DeleteChat)
+	chat.POST(":id/member", ctrl.RequireUser, chatsWrite, ctrl.AddChatMember)
+
+	tag := eng.Group("/tag")
+	tag.GET(":name/posts", postsRead, ctrl.GetTagPosts)
+
+	auth := eng.Group("/auth")
+	auth.POST("/verify", ctrl.VerifyEmail)
+	auth.POST("/verify/resend", ctrl.ResendVerification)
+	auth.POST("/login", ctrl.Login)
+	auth.POST("/login/2fa", ctrl.LoginTwoFactor)
+	auth.POST("/logout", ctrl.Logout)
+	auth.POST("/password/forgot", ctrl.ForgotPassword)
+	auth.POST("/password/reset", ctrl.ResetPassword)
+	auth.POST("/unlock", ctrl.Unlock)
+
+	twoFactor := auth.Group("/2fa", ctrl.RequireUser, ctrl.RequireSession)
+	twoFactor.POST("/enroll", ctrl.EnrollTwoFactor)
+	twoFactor.POST("/confirm", ctrl.ConfirmTwoFactor)
+
+	apiKeys := auth.Group("/api-keys", ctrl.RequireUser, ctrl.RequireSession)
+	apiKeys.POST("", ctrl.CreateAPIKey)
+	apiKeys.GET("", ctrl.GetAPIKeys)
+	apiKeys.DELETE(":id", ctrl.RevokeAPIKey)
+
+	admin := eng.Group(
+		"/admin",
+		ctrl.RequireUser,
+		ctrl.RequireSession,
+		ctrl.RequireTwoFactor,
+		ctrl.RequireRole(database.RoleAdmin),
+	)
+	admin.GET("/2fa-policy", ctrl.GetAllTwoFactorPolicies)
+	admin.PUT("/2fa-policy/:role", ctrl.SetTwoFactorPolicy)
+	admin.POST("/user/:id/unlock", ctrl.UnlockUser)
+	admin.POST("/bot", ctrl.AddBot)
+	admin.GET("/bot", ctrl.GetAllBots)
+	admin.POST("/webhook", ctrl.AddWebhookSubscription)
+	admin.GET("/webhook", ctrl.GetAllWebhookSubscriptions)
+	admin.DELETE("/webhook/:id", ctrl.DeleteWebhookSubscription)
+	admin.GET("/webhook/:id/deliveries", ctrl.GetWebhookDeliveries)
+	admin.POST("/webhook-delivery/:id/redeliver", ctrl.RedeliverWebhook)
+	admin.POST("/chat/:id/incoming-webhook", ctrl.AddIncomingWebhook)
+	admin.GET("/chat/:id/incoming-webhook", ctrl.GetChatIncomingWebhooks)
+	admin.PUT("/incoming-webhook/:id", ctrl.UpdateIncomingWebhook)
+	admin.POST("/incoming-webhook/:id/rotate", ctrl.RotateIncomingWebhook)
+
+	eng.POST("/hooks/:token", ctrl.PostIncomingWebhook)
+
+	notifications := eng.Group("/notifications", ctrl.RequireUser)
+	notifications.GET("", notificationsRead, ctrl.GetNotifications)
+	notifications.GET("/stream", notificationsRead, ctrl.StreamNotifications)
+	notifications.POST(":id/read", notificationsWrite, ctrl.MarkNotificationRead)
+	notifications.POST("/read-all", notificationsWrite, ctrl.MarkAllNotificationsRead)
 
 	return &Router{
 		engine: eng,
 	}
 }
 
-func (r *Router) Run(address string) {
-	r.engine.Run(address)
+// HasRoute reports whether the route, keyed like "GET /post/:id", is registered.
+func (r *Router) HasRoute(route string) bool {
+	for _, info := range r.engine.Routes() {
+		if fmt.Sprintf("%s %s", info.Method, info.Path) == route {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Server returns the HTTP server of the routes. The caller runs and shuts it down.
+func (r *Router) Server(cfg *config.API) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Address,
+		Handler:           r.engine,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		MaxHeaderBytes:    int(cfg.MaxHeaderSize),
+	}
 }
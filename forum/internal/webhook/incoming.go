@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+	"gorm.io/gorm"
+)
+
+const (
+	// IncomingBotNickname is the bot that sends the messages of incoming webhooks.
+	IncomingBotNickname = "webhook"
+
+	incomingTokenPrefix = "fwh_"
+	maxIncomingText     = 10000
+	maxAttachments      = 10
+	maxUsername         = 64
+)
+
+var (
+	ErrHookNotFound       = errors.New("incoming webhook is not found or disabled")
+	ErrInvalidIncoming    = errors.New("incoming webhook needs a name")
+	ErrInvalidHookPayload = errors.New(
+		"payload needs text of at most 10000 characters and at most 10 attachments with urls",
+	)
+)
+
+type IncomingDB interface {
+	EnsureBot(nickname string) (*database.Bot, error)
+	AddMessage(ctx context.Context, message *database.Message) error
+	AddIncomingWebhook(ctx context.Context, hook *database.IncomingWebhook) error
+	GetActiveIncomingWebhook(tokenHash string) (*database.IncomingWebhook, error)
+	SetIncomingWebhookToken(id uint, tokenHash string) (*database.IncomingWebhook, error)
+}
+
+// Attachment is a link shown under the text of an incoming message.
+type Attachment struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Text  string `json:"text"`
+}
+
+// Payload is the body accepted by incoming webhooks.
+type Payload struct {
+	Text        string       `json:"text"`
+	Username    string       `json:"username"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Incoming turns requests to incoming webhooks into chat messages.
+type Incoming struct {
+	db IncomingDB
+}
+
+func NewIncoming(db IncomingDB) *Incoming {
+	return &Incoming{
+		db: db,
+	}
+}
+
+// Create adds an incoming webhook to the chat. The returned token is shown only once.
+func (i *Incoming) Create(
+	ctx context.Context,
+	chatID uint,
+	name string,
+) (*database.IncomingWebhook, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrInvalidIncoming
+	}
+
+	token, err := newIncomingToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("newIncomingToken(): %w", err)
+	}
+
+	hook := &database.IncomingWebhook{
+		ChatID:    chatID,
+		Name:      name,
+		TokenHash: hashToken(token),
+	}
+	if err := i.db.AddIncomingWebhook(ctx, hook); err != nil {
+		return nil, "", fmt.Errorf("i.db.AddIncomingWebhook(): %w", err)
+	}
+
+	return hook, token, nil
+}
+
+// Rotate replaces the token of the webhook, the old one stops working at once.
+func (i *Incoming) Rotate(id uint) (*database.IncomingWebhook, string, error) {
+	token, err := newIncomingToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("newIncomingToken(): %w", err)
+	}
+
+	hook, err := i.db.SetIncomingWebhookToken(id, hashToken(token))
+	if err != nil {
+		return nil, "", fmt.Errorf("i.db.SetIncomingWebhookToken(%d): %w", id, err)
+	}
+
+	return hook, token, nil
+}
+
+// Post sends the payload to the chat of the webhook with the token. The message
+// is sent by the webhook bot and shown under the username or the webhook name.
+func (i *Incoming) Post(
+	ctx context.Context,
+	token string,
+	payload *Payload,
+) (*database.Message, error) {
+	content, err := payload.content()
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := i.db.GetActiveIncomingWebhook(hashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHookNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("i.db.GetActiveIncomingWebhook(): %w", err)
+	}
+
+	bot, err := i.db.EnsureBot(IncomingBotNickname)
+	if err != nil {
+		return nil, fmt.Errorf("i.db.EnsureBot(%q): %w", IncomingBotNickname, err)
+	}
+
+	senderName := strings.TrimSpace(payload.Username)
+	if senderName == "" {
+		senderName = hook.Name
+	}
+
+	message := &database.Message{
+		Content:    content,
+		SenderID:   bot.UserID,
+		SenderName: senderName,
+		ChatID:     hook.ChatID,
+	}
+	if err := i.db.AddMessage(ctx, message); err != nil {
+		return nil, fmt.Errorf("i.db.AddMessage(): %w", err)
+	}
+
+	return message, nil
+}
+
+// content renders the text and the attachments as markdown.
+func (p *Payload) content() (string, error) {
+	text := strings.TrimSpace(p.Text)
+	if (text == "" && len(p.Attachments) == 0) || len([]rune(text)) > maxIncomingText ||
+		len(p.Attachments) > maxAttachments || len([]rune(p.Username)) > maxUsername {
+		return "", ErrInvalidHookPayload
+	}
+
+	var content strings.Builder
+	content.WriteString(text)
+	for _, attachment := range p.Attachments {
+		if !ValidURL(attachment.URL) {
+			return "", ErrInvalidHookPayload
+		}
+
+		title := strings.TrimSpace(attachment.Title)
+		if title == "" {
+			title = attachment.URL
+		}
+
+		fmt.Fprintf(&content, "\n\n**[%s](<%s>)**", title, attachment.URL)
+		if attachmentText := strings.TrimSpace(attachment.Text); attachmentText != "" {
+			fmt.Fprintf(&content, "\n%s", attachmentText)
+		}
+	}
+
+	return strings.TrimSpace(content.String()), nil
+}
+
+func newIncomingToken() (string, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", fmt.Errorf("NewSecret(): %w", err)
+	}
+
+	return incomingTokenPrefix + secret, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the request body signed with the secret.
+	SignatureHeader = "X-Forum-Signature"
+	EventHeader     = "X-Forum-Event"
+	DeliveryHeader  = "X-Forum-Delivery"
+
+	pollInterval    = 5 * time.Second
+	deliveryTimeout = 10 * time.Second
+	deliveryWorkers = 10
+	claimLimit      = 50
+	maxAttempts     = 8
+	baseBackoff     = 30 * time.Second
+	maxErrorSize    = 1 << 10
+	secretSize      = 32
+
+	// claimLease covers twice the time the workers take to send a whole batch,
+	// so other replicas do not claim the deliveries again while they are sent.
+	claimLease = 2 * claimLimit / deliveryWorkers * deliveryTimeout
+)
+
+var ErrInvalidSubscription = errors.New(
+	"webhook needs an absolute http or https url and known event types",
+)
+
+type WebhookDB interface {
+	AddWebhookSubscription(subscription *database.WebhookSubscription) error
+	ClaimWebhookDeliveries(
+		now time.Time,
+		lease time.Duration,
+		limit int,
+	) ([]*database.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery *database.WebhookDelivery) error
+}
+
+// Sign returns the signature of the body for the SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret for signing requests.
+func NewSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("cannot generate secret: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// ValidURL reports whether webhooks can be sent to the URL.
+func ValidURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+
+	return err == nil && parsed.IsAbs() && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
+
+// Deliverer sends queued events to the subscriptions and retries failed
+// deliveries with exponential backoff.
+type Deliverer struct {
+	db     WebhookDB
+	client *http.Client
+	logger *slog.Logger
+}
+
+func NewDeliverer(db WebhookDB, logger *slog.Logger) *Deliverer {
+	return &Deliverer{
+		db:     db,
+		client: &http.Client{Timeout: deliveryTimeout},
+		logger: logger,
+	}
+}
+
+// Subscribe creates a subscription to the events. The returned secret signs
+// the deliveries and is shown only once.
+func (d *Deliverer) Subscribe(
+	rawURL string,
+	events []string,
+) (*database.WebhookSubscription, string, error) {
+	if !ValidURL(rawURL) || len(events) == 0 {
+		return nil, "", ErrInvalidSubscription
+	}
+
+	for _, event := range events {
+		if !slices.Contains(database.Events, event) {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidSubscription, event)
+		}
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("NewSecret(): %w", err)
+	}
+
+	subscription := &database.WebhookSubscription{
+		URL:    rawURL,
+		Events: slices.Compact(slices.Sorted(slices.Values(events))),
+		Secret: secret,
+	}
+	if err := d.db.AddWebhookSubscription(subscription); err != nil {
+		return nil, "", fmt.Errorf("d.db.AddWebhookSubscription(): %w", err)
+	}
+
+	return subscription, secret, nil
+}
+
+// Run delivers queued events until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Deliverer) deliverDue(ctx context.Context) {
+	deliveries, err := d.db.ClaimWebhookDeliveries(time.Now(), claimLease, claimLimit)
+	if err != nil {
+		d.logger.Error(
+			fmt.Sprintf("d.db.ClaimWebhookDeliveries(): %s", err),
+			"method",
+			"webhook.deliverDue",
+		)
+		return
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, deliveryWorkers)
+
+	for _, delivery := range deliveries {
+		workers <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			d.attempt(ctx, delivery)
+
+			if err := d.db.UpdateWebhookDelivery(delivery); err != nil {
+				d.logger.Error(
+					fmt.Sprintf("d.db.UpdateWebhookDelivery(%d): %s", delivery.ID, err),
+					"method",
+					"webhook.deliverDue",
+				)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// attempt sends the delivery once and records the outcome in it.
+func (d *Deliverer) attempt(ctx context.Context, delivery *database.WebhookDelivery) {
+	now := time.Now()
+	delivery.Attempts++
+
+	statusCode, err := d.send(ctx, delivery)
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Status = database.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = database.DeliveryFailed
+		delivery.NextAttemptAt = nil
+
+		return
+	}
+
+	next := now.Add(baseBackoff << (delivery.Attempts - 1))
+	delivery.NextAttemptAt = &next
+}
+
+func (d *Deliverer) send(ctx context.Context, delivery *database.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		delivery.Subscription.URL,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("cannot create request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(delivery.Subscription.Secret, body))
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("cannot send request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorSize))
+
+		return response.StatusCode, fmt.Errorf(
+			"receiver responded with status %d: %s",
+			response.StatusCode,
+			snippet,
+		)
+	}
+
+	return response.StatusCode, nil
+}
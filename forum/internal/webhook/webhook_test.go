@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/forum/internal/database"
+)
+
+// memoryDB hands out the queued deliveries once and keeps the updated ones.
+type memoryDB struct {
+	WebhookDB
+	mu      sync.Mutex
+	queued  []*database.WebhookDelivery
+	updated []*database.WebhookDelivery
+}
+
+func (db *memoryDB) ClaimWebhookDeliveries(
+	time.Time,
+	time.Duration,
+	int,
+) ([]*database.WebhookDelivery, error) {
+	claimed := db.queued
+	db.queued = nil
+
+	return claimed, nil
+}
+
+func (db *memoryDB) UpdateWebhookDelivery(delivery *database.WebhookDelivery) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.updated = append(db.updated, delivery)
+
+	return nil
+}
+
+func newDelivery(url string, attempts int) *database.WebhookDelivery {
+	return &database.WebhookDelivery{
+		ID:       42,
+		Event:    database.EventPostCreated,
+		Payload:  `{"id":1}`,
+		Status:   database.DeliveryPending,
+		Attempts: attempts,
+		Subscription: database.WebhookSubscription{
+			URL:    url,
+			Secret: "secret",
+		},
+	}
+}
+
+// deliver runs one delivery round against the receiver and returns the updated delivery.
+func deliver(
+	t *testing.T,
+	receiver http.HandlerFunc,
+	attempts int,
+) *database.WebhookDelivery {
+	t.Helper()
+
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	db := &memoryDB{queued: []*database.WebhookDelivery{newDelivery(server.URL, attempts)}}
+	deliverer := NewDeliverer(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	deliverer.deliverDue(context.Background())
+
+	if len(db.updated) != 1 {
+		t.Fatalf("got %d updated deliveries, want 1", len(db.updated))
+	}
+
+	return db.updated[0]
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	delivery := deliver(t, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read body: %s", err)
+		}
+
+		if got, want := string(body), `{"id":1}`; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+
+		if got, want := r.Header.Get(SignatureHeader), Sign("secret", body); got != want {
+			t.Errorf("got signature %q, want %q", got, want)
+		}
+
+		if got, want := r.Header.Get(EventHeader), database.EventPostCreated; got != want {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+
+		if got, want := r.Header.Get(DeliveryHeader), "42"; got != want {
+			t.Errorf("got delivery %q, want %q", got, want)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}, 0)
+
+	if delivery.Status != database.DeliveryDelivered {
+		t.Errorf("got status %q, want %q", delivery.Status, database.DeliveryDelivered)
+	}
+
+	if delivery.StatusCode != http.StatusNoContent {
+		t.Errorf("got status code %d, want %d", delivery.StatusCode, http.StatusNoContent)
+	}
+
+	if delivery.Attempts != 1 || delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil {
+		t.Errorf(
+			"got attempts %d, delivered at %v and next attempt at %v after delivery",
+			delivery.Attempts,
+			delivery.DeliveredAt,
+			delivery.NextAttemptAt,
+		)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The HMAC-SHA256 of {"id":1} with the key "secret".
+	want := "sha256=03def589620c813f198fd03d7967e292b163ef0435ebf43071ce0e9519763cb7"
+	if got := Sign("secret", []byte(`{"id":1}`)); got != want {
+		t.Errorf("got signature %q, want %q", got, want)
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	for _, attempts := range []int{0, 1, 4} {
+		start := time.Now()
+		delivery := deliver(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "receiver is down", http.StatusServiceUnavailable)
+		}, attempts)
+
+		if delivery.Status != database.DeliveryPending {
+			t.Errorf("got status %q, want %q", delivery.Status, database.DeliveryPending)
+		}
+
+		if delivery.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf(
+				"got status code %d, want %d",
+				delivery.StatusCode,
+				http.StatusServiceUnavailable,
+			)
+		}
+
+		if delivery.Attempts != attempts+1 {
+			t.Errorf("got attempts %d, want %d", delivery.Attempts, attempts+1)
+		}
+
+		backoff := baseBackoff << attempts
+		if delivery.NextAttemptAt == nil ||
+			delivery.NextAttemptAt.Before(start.Add(backoff)) ||
+			delivery.NextAttemptAt.After(time.Now().Add(backoff)) {
+			t.Errorf(
+				"got next attempt at %v after %d attempts, want in %s",
+				delivery.NextAttemptAt,
+				delivery.Attempts,
+				backoff,
+			)
+		}
+
+		if !strings.Contains(delivery.LastError, "receiver is down") {
+			t.Errorf("got last error %q, want the response body in it", delivery.LastError)
+		}
+	}
+}
+
+func TestDeliverFailsAfterMaxAttempts(t *testing.T) {
+	delivery := deliver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, maxAttempts-1)
+
+	if delivery.Status != database.DeliveryFailed {
+		t.Errorf("got status %q, want %q", delivery.Status, database.DeliveryFailed)
+	}
+
+	if delivery.Attempts != maxAttempts || delivery.NextAttemptAt != nil {
+		t.Errorf(
+			"got attempts %d and next attempt at %v, want %d and none",
+			delivery.Attempts,
+			delivery.NextAttemptAt,
+			maxAttempts,
+		)
+	}
+}
+
+func TestDeliverKeepsErrorSnippet(t *testing.T) {
+	delivery := deliver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, strings.Repeat("x", 4*maxErrorSize))
+	}, 0)
+
+	snippet := strings.Repeat("x", maxErrorSize)
+	if !strings.HasSuffix(delivery.LastError, ": "+snippet) {
+		t.Errorf(
+			"got last error of %d bytes, want the first %d bytes of the body",
+			len(delivery.LastError),
+			maxErrorSize,
+		)
+	}
+}
+
+func TestDeliverSendsBatchConcurrently(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	db := &memoryDB{}
+	for range claimLimit {
+		db.queued = append(db.queued, newDelivery(server.URL, 0))
+	}
+
+	deliverer := NewDeliverer(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	deliverer.deliverDue(context.Background())
+
+	if len(db.updated) != claimLimit {
+		t.Fatalf("got %d updated deliveries, want %d", len(db.updated), claimLimit)
+	}
+
+	if got := maxInFlight.Load(); got < 2 || got > deliveryWorkers {
+		t.Errorf("got %d deliveries in flight, want between 2 and %d", got, deliveryWorkers)
+	}
+
+	if batch := claimLimit / deliveryWorkers * deliveryTimeout; claimLease < batch {
+		t.Errorf("got claim lease %s, want at least %s to send a batch", claimLease, batch)
+	}
+}